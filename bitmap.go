@@ -0,0 +1,84 @@
+package maxminddb
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"slices"
+)
+
+// Bitmap is a compact, read-only set of the IPv4 addresses that have a
+// record in a database, as returned by Reader.ExportIPv4Bitmap. It is safe
+// for concurrent use.
+type Bitmap struct {
+	// ranges are sorted, non-overlapping, and non-adjacent.
+	ranges []ipv4Range
+}
+
+type ipv4Range struct {
+	first, last uint32
+}
+
+// ExportIPv4Bitmap returns the set of IPv4 addresses that have a record in
+// the database. Checking whether an address is in the set with
+// Bitmap.Contains is faster than Lookup as it does not traverse the search
+// tree. The set does not change if the Reader is closed.
+//
+// For an IPv6 database, the IPv4 addresses are those in the IPv4 subtree,
+// ::/96, which are the addresses that Lookup uses for IPv4 addresses.
+func (r *Reader) ExportIPv4Bitmap() (*Bitmap, error) {
+	if r.buffer == nil {
+		return nil, errors.New("cannot call ExportIPv4Bitmap on a closed database")
+	}
+
+	b := &Bitmap{}
+	for result := range r.NetworksWithin(netip.MustParsePrefix("0.0.0.0/0")) {
+		if err := result.Err(); err != nil {
+			return nil, err
+		}
+		next := ipv4Range{0, ^uint32(0)}
+		// A record above the IPv4 subtree applies to every IPv4 address.
+		if result.prefixLen > 96 {
+			prefix := result.Prefix()
+			next.first = binary.BigEndian.Uint32(prefix.Addr().AsSlice())
+			next.last = next.first | (^uint32(0) >> prefix.Bits())
+		}
+		b.add(next)
+	}
+	return b, nil
+}
+
+// add adds next, which must not start before any of the existing ranges.
+func (b *Bitmap) add(next ipv4Range) {
+	if n := len(b.ranges); n > 0 {
+		last := &b.ranges[n-1]
+		if last.last != ^uint32(0) && last.last+1 >= next.first {
+			last.last = max(last.last, next.last)
+			return
+		}
+	}
+	b.ranges = append(b.ranges, next)
+}
+
+// Contains reports whether ip has a record in the database the Bitmap was
+// exported from. IPv4-mapped IPv6 addresses are treated as IPv4 addresses.
+// Other IPv6 addresses are never contained.
+func (b *Bitmap) Contains(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.Is4() {
+		return false
+	}
+	a := ip.As4()
+	v := binary.BigEndian.Uint32(a[:])
+	_, found := slices.BinarySearchFunc(b.ranges, v, func(r ipv4Range, v uint32) int {
+		switch {
+		case r.last < v:
+			return -1
+		case r.first > v:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return found
+}
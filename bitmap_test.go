@@ -0,0 +1,69 @@
+package maxminddb
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportIPv4Bitmap(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-no-ipv4-search-tree.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			bitmap, err := reader.ExportIPv4Bitmap()
+			require.NoError(t, err)
+
+			var ips []netip.Addr
+			for result := range reader.NetworksWithin(
+				netip.MustParsePrefix("0.0.0.0/0"),
+				IncludeNetworksWithoutData,
+			) {
+				require.NoError(t, result.Err())
+				if prefix := result.Prefix(); prefix.Addr().Is4() {
+					ips = append(ips, prefix.Addr(), lastAddr(prefix))
+					ips = append(ips, prefix.Addr().Prev(), lastAddr(prefix).Next())
+				}
+			}
+			r := rand.New(rand.NewSource(0))
+			s := make([]byte, 4)
+			for range 10000 {
+				ips = append(ips, randomIPv4Address(r, s))
+			}
+
+			for _, ip := range ips {
+				if !ip.IsValid() {
+					continue
+				}
+				assert.Equal(t, reader.Lookup(ip).Found(), bitmap.Contains(ip), ip)
+			}
+
+			assert.False(t, bitmap.Contains(netip.MustParseAddr("2001::1")))
+			assert.Equal(
+				t,
+				bitmap.Contains(netip.MustParseAddr("1.1.1.1")),
+				bitmap.Contains(netip.MustParseAddr("::ffff:1.1.1.1")),
+			)
+		})
+	}
+
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	bitmap, err := reader.ExportIPv4Bitmap()
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.True(t, bitmap.Contains(netip.MustParseAddr("1.1.1.1")))
+
+	_, err = reader.ExportIPv4Bitmap()
+	require.Error(t, err)
+}
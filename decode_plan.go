@@ -0,0 +1,115 @@
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// DecodePlan is a precomputed mapping from the keys of a map record to the
+// fields of a struct type. It is intended for use by code generators, which
+// can compute the field index paths once rather than relying on the
+// maxminddb struct tags. Values below the top level are decoded as usual.
+//
+// A DecodePlan is immutable and may be shared across goroutines.
+type DecodePlan struct {
+	typ    reflect.Type
+	fields map[string][]int
+}
+
+// NewDecodePlan creates a DecodePlan for the struct type of v, which must be
+// a struct or a pointer to a struct. fields maps record keys to the index
+// path of the destination field, as used by reflect.Value.FieldByIndex.
+// Paths may descend into embedded or nested struct fields, but not through
+// pointers. Keys that are not in fields are skipped when decoding.
+func NewDecodePlan(v any, fields map[string][]int) (*DecodePlan, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot create a decode plan for %v: not a struct", t)
+	}
+
+	planFields := make(map[string][]int, len(fields))
+	for key, index := range fields {
+		if len(index) == 0 {
+			return nil, fmt.Errorf("empty field index for key %q", key)
+		}
+		ft := t
+		for _, i := range index {
+			if ft.Kind() != reflect.Struct || i < 0 || i >= ft.NumField() {
+				return nil, fmt.Errorf("invalid field index %v for key %q in %s", index, key, t)
+			}
+			ft = ft.Field(i).Type
+		}
+		planFields[key] = append([]int(nil), index...)
+	}
+	return &DecodePlan{typ: t, fields: planFields}, nil
+}
+
+// DecodeWithPlan unmarshals the record into v, which must be a non-nil
+// pointer to the struct type the plan was created for. Top-level keys are
+// assigned to fields using the plan rather than the struct tags. Otherwise,
+// it behaves like Decode.
+func (r Result) DecodeWithPlan(v any, plan *DecodePlan) (err error) {
+	defer r.recoverPanic(&err)
+	if plan == nil {
+		return errors.New("decode plan must not be nil")
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if r.offset == notFound {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("result param must be a pointer")
+	}
+	if rv.Elem().Type() != plan.typ {
+		return fmt.Errorf("decode plan is for %s, not %s", plan.typ, rv.Elem().Type())
+	}
+	if err := r.checkEmpty(); err != nil {
+		return err
+	}
+
+	return r.decoder.decodeWithPlan(r.offset, rv.Elem(), plan, &decodeState{addr: r.ip})
+}
+
+func (d *decoder) decodeWithPlan(
+	offset uint,
+	result reflect.Value,
+	plan *DecodePlan,
+	state *decodeState,
+) error {
+	typeNum, size, offset, err := d.decodeCtrlDataAndFollow(offset)
+	if err != nil {
+		return err
+	}
+	if typeNum != _Map {
+		return newUnmarshalTypeStrError(fmt.Sprintf("type %d", typeNum), result.Type())
+	}
+
+	for i := uint(0); i < size; i++ {
+		var key []byte
+		key, offset, err = d.decodeKey(offset)
+		if err != nil {
+			return err
+		}
+		index, ok := plan.fields[string(key)]
+		if !ok {
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		offset, err = d.decodeValue(offset, result.FieldByIndex(index), 1, state)
+		if err != nil {
+			return fmt.Errorf("decoding value for %s: %w", key, err)
+		}
+	}
+	return nil
+}
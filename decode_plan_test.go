@@ -0,0 +1,102 @@
+package maxminddb
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cityDecodePlan(tb testing.TB) *DecodePlan {
+	plan, err := NewDecodePlan(fullCity{}, map[string][]int{
+		"city":                {0},
+		"continent":           {1},
+		"country":             {2},
+		"location":            {3},
+		"postal":              {4},
+		"registered_country":  {5},
+		"represented_country": {6},
+		"subdivisions":        {7},
+		"traits":              {8},
+	})
+	require.NoError(tb, err)
+	return plan
+}
+
+func TestDecodeWithPlan(t *testing.T) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	result := db.Lookup(netip.MustParseAddr("81.2.69.142"))
+
+	var expected fullCity
+	require.NoError(t, result.Decode(&expected))
+
+	var actual fullCity
+	require.NoError(t, result.DecodeWithPlan(&actual, cityDecodePlan(t)))
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, "GB", actual.Country.IsoCode)
+
+	var wrongType struct{}
+	require.Error(t, result.DecodeWithPlan(&wrongType, cityDecodePlan(t)))
+
+	require.EqualError(t, result.DecodeWithPlan(&actual, nil), "decode plan must not be nil")
+
+	var country struct {
+		Country struct {
+			ISOCode string     `maxminddb:"iso_code"`
+			IP      netip.Addr `maxminddb:",addr"`
+		}
+	}
+	plan, err := NewDecodePlan(&country, map[string][]int{"country": {0}})
+	require.NoError(t, err)
+	require.NoError(t, result.DecodeWithPlan(&country, plan))
+	assert.Equal(t, "GB", country.Country.ISOCode)
+	assert.Equal(t, netip.MustParseAddr("81.2.69.142"), country.Country.IP)
+}
+
+func TestNewDecodePlanErrors(t *testing.T) {
+	_, err := NewDecodePlan("not a struct", nil)
+	require.Error(t, err)
+
+	_, err = NewDecodePlan(fullCity{}, map[string][]int{"city": {100}})
+	require.Error(t, err)
+
+	_, err = NewDecodePlan(fullCity{}, map[string][]int{"city": {0, 1, 0}})
+	require.Error(t, err)
+
+	plan, err := NewDecodePlan(&fullCity{}, map[string][]int{"city": {0, 0}})
+	require.NoError(t, err)
+	assert.NotNil(t, plan)
+}
+
+func BenchmarkCityDecodeWithTags(b *testing.B) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(b, err)
+
+	result := db.Lookup(netip.MustParseAddr("81.2.69.142"))
+	var record fullCity
+	for i := 0; i < b.N; i++ {
+		if err := result.Decode(&record); err != nil {
+			b.Error(err)
+		}
+	}
+	require.NoError(b, db.Close(), "error on close")
+}
+
+func BenchmarkCityDecodeWithPlan(b *testing.B) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(b, err)
+
+	plan := cityDecodePlan(b)
+	result := db.Lookup(netip.MustParseAddr("81.2.69.142"))
+	var record fullCity
+	for i := 0; i < b.N; i++ {
+		if err := result.DecodeWithPlan(&record, plan); err != nil {
+			b.Error(err)
+		}
+	}
+	require.NoError(b, db.Close(), "error on close")
+}
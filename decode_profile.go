@@ -0,0 +1,128 @@
+package maxminddb
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+)
+
+// DecodeProfile counts the values of each type in the data section that were
+// decoded by Result.DecodeWithProfile. Pointers are counted in addition to
+// the values they point to. Map keys and values that were skipped, e.g.,
+// those without a corresponding struct field, are not counted.
+//
+// The counts accumulate across calls. A DecodeProfile must not be used by
+// multiple goroutines at once.
+type DecodeProfile struct {
+	Pointers uint
+	Strings  uint
+	Float64s uint
+	Bytes    uint
+	Uint16s  uint
+	Uint32s  uint
+	Maps     uint
+	Int32s   uint
+	Uint64s  uint
+	Uint128s uint
+	Slices   uint
+	Bools    uint
+	Float32s uint
+}
+
+func (p *DecodeProfile) add(typeNum dataType) {
+	switch typeNum {
+	case _Pointer:
+		p.Pointers++
+	case _String:
+		p.Strings++
+	case _Float64:
+		p.Float64s++
+	case _Bytes:
+		p.Bytes++
+	case _Uint16:
+		p.Uint16s++
+	case _Uint32:
+		p.Uint32s++
+	case _Map:
+		p.Maps++
+	case _Int32:
+		p.Int32s++
+	case _Uint64:
+		p.Uint64s++
+	case _Uint128:
+		p.Uint128s++
+	case _Slice:
+		p.Slices++
+	case _Bool:
+		p.Bools++
+	case _Float32:
+		p.Float32s++
+	}
+}
+
+// DecodeWithProfile unmarshals the record into v like Decode while adding
+// the types of the decoded values to p. This is intended for understanding
+// the shape and cost of decoding a record.
+func (r Result) DecodeWithProfile(v any, p *DecodeProfile) error {
+	if p != nil {
+		r.decoder.profile = p
+	}
+	return r.Decode(v)
+}
+
+// ProfileDecode returns the average number of heap allocations made when
+// decoding a record into a value of the type that v points to. The records
+// are those of the first samples networks returned by Networks, repeated if
+// there are fewer networks. Each record is decoded into the same value, which
+// is zeroed first, so allocations to reuse existing maps and slices are not
+// counted. v itself is not modified.
+//
+// This is intended for comparing the cost of struct designs, e.g., pointer
+// and value fields, without writing benchmarks. As with
+// testing.AllocsPerRun, allocations by other goroutines during the
+// measurement are included.
+func (r *Reader) ProfileDecode(v any, samples int) (allocsPerOp float64, err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return 0, errors.New("result param must be a pointer")
+	}
+	if samples <= 0 {
+		return 0, errors.New("samples must be positive")
+	}
+
+	var results []Result
+	for result := range r.Networks() {
+		if err := result.Err(); err != nil {
+			return 0, err
+		}
+		results = append(results, result)
+		if len(results) == samples {
+			break
+		}
+	}
+	if len(results) == 0 {
+		return 0, errors.New("the database has no records to decode")
+	}
+
+	value := reflect.New(rv.Type().Elem())
+	target := value.Interface()
+	// This warms up the cached struct fields so that they are not counted.
+	if err := results[0].Decode(target); err != nil {
+		return 0, err
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	before := memStats.Mallocs
+
+	for i := range samples {
+		value.Elem().SetZero()
+		if err := results[i%len(results)].Decode(target); err != nil {
+			return 0, err
+		}
+	}
+
+	runtime.ReadMemStats(&memStats)
+
+	return float64(memStats.Mallocs-before) / float64(samples), nil
+}
@@ -0,0 +1,102 @@
+package maxminddb
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeWithProfile(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("81.2.69.142"))
+
+	var profile DecodeProfile
+	var record any
+	require.NoError(t, result.DecodeWithProfile(&record, &profile))
+
+	var maps, strings, float64s uint
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			maps++
+			for _, value := range v {
+				walk(value)
+			}
+		case []any:
+			for _, value := range v {
+				walk(value)
+			}
+		case string:
+			strings++
+		case float64:
+			float64s++
+		}
+	}
+	walk(record)
+
+	assert.Equal(t, maps, profile.Maps)
+	assert.Equal(t, strings, profile.Strings)
+	assert.Equal(t, float64s, profile.Float64s)
+	assert.Equal(t, uint(2), profile.Float64s)
+	assert.Equal(t, uint(1), profile.Slices)
+
+	// Only the decoded fields are counted.
+	var cityOnly struct {
+		City struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"city"`
+	}
+	var cityProfile DecodeProfile
+	require.NoError(t, result.DecodeWithProfile(&cityOnly, &cityProfile))
+	assert.Equal(t, uint(3), cityProfile.Maps)
+	assert.Zero(t, cityProfile.Float64s)
+
+	// Counts accumulate across calls.
+	require.NoError(t, result.DecodeWithProfile(&record, &profile))
+	assert.Equal(t, 2*maps, profile.Maps)
+}
+
+func TestProfileDecode(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	type city struct {
+		City struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"city"`
+		Location struct {
+			Latitude  float64 `maxminddb:"latitude"`
+			Longitude float64 `maxminddb:"longitude"`
+		} `maxminddb:"location"`
+	}
+	cityAllocs, err := reader.ProfileDecode(&city{}, 20)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, cityAllocs, 0.0)
+
+	var location struct {
+		Location struct {
+			Latitude float64 `maxminddb:"latitude"`
+		} `maxminddb:"location"`
+	}
+	locationAllocs, err := reader.ProfileDecode(&location, 20)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, locationAllocs, 0.0)
+
+	anyAllocs, err := reader.ProfileDecode(new(any), 20)
+	require.NoError(t, err)
+	assert.Greater(t, anyAllocs, locationAllocs)
+
+	_, err = reader.ProfileDecode(city{}, 20)
+	require.Error(t, err)
+	_, err = reader.ProfileDecode(&city{}, 0)
+	require.Error(t, err)
+	_, err = reader.ProfileDecode(new(string), 1)
+	require.ErrorAs(t, err, &UnmarshalTypeError{})
+}
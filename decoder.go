@@ -1,16 +1,75 @@
 package maxminddb
 
 import (
+	"cmp"
+	"encoding"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
+	"net/netip"
 	"reflect"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 )
 
 type decoder struct {
 	buffer []byte
+	opts   decoderOptions
+	// profile is set by Result.DecodeWithProfile.
+	profile *DecodeProfile
+}
+
+// decodeState is the state of a single call that decodes a value into a Go
+// value. It is passed down to the functions that need it rather than being
+// stored in the decoder, which every Result holds a copy of.
+type decodeState struct {
+	// addr is the IP address of the Result being decoded, which is stored in
+	// fields with the addr tag option.
+	addr netip.Addr
+	// skipKeys are the keys that are skipped rather than decoded in the next
+	// map that is decoded. It is set by Result.DecodeExcept when the record
+	// is a map, so it only applies to the record's top-level keys.
+	skipKeys []string
+	// anyDepth is the current nesting of maps and arrays being decoded into
+	// empty interfaces. It is checked against opts.maxDecodeDepth.
+	anyDepth int
+}
+
+// decoderOptions holds the ReaderOption settings that affect how values are
+// decoded.
+type decoderOptions struct {
+	logger                  *readerLogger
+	maxDecodeDepth          int
+	collectErrors           bool
+	disablePointerFollowing bool
+	reportEmpty             bool
+	trimStrings             bool
+	floatAsString           bool
+	untaggedLowercase       bool
+	recoverPanics           bool
+	// reportValueErrors wraps the first error returned for a value in a
+	// *valueError. It is only set by the verifier.
+	reportValueErrors bool
+}
+
+// readerLogger reports non-fatal anomalies to the function passed to
+// WithLogger. Each distinct message is only reported once per Reader.
+type readerLogger struct {
+	fn       func(msg string)
+	reported sync.Map
+}
+
+func (l *readerLogger) log(msg string) {
+	if l == nil {
+		return
+	}
+	if _, loaded := l.reported.LoadOrStore(msg, struct{}{}); !loaded {
+		l.fn(msg)
+	}
 }
 
 type dataType int
@@ -36,12 +95,63 @@ const (
 	_Float32
 )
 
+// String returns the name of the type in the MaxMind DB spec.
+func (t dataType) String() string {
+	switch t {
+	case _Extended:
+		return "extended"
+	case _Pointer:
+		return "pointer"
+	case _String:
+		return "utf8_string"
+	case _Float64:
+		return "double"
+	case _Bytes:
+		return "bytes"
+	case _Uint16:
+		return "uint16"
+	case _Uint32:
+		return "uint32"
+	case _Map:
+		return "map"
+	case _Int32:
+		return "int32"
+	case _Uint64:
+		return "uint64"
+	case _Uint128:
+		return "uint128"
+	case _Slice:
+		return "array"
+	case _Container:
+		return "data cache container"
+	case _Marker:
+		return "end marker"
+	case _Bool:
+		return "boolean"
+	case _Float32:
+		return "float"
+	default:
+		return fmt.Sprintf("unknown type %d", int(t))
+	}
+}
+
 const (
 	// This is the value used in libmaxminddb.
 	maximumDataStructureDepth = 512
 )
 
 func (d *decoder) decode(offset uint, result reflect.Value, depth int) (uint, error) {
+	return d.decodeValue(offset, result, depth, &decodeState{})
+}
+
+// decodeValue decodes the value at offset into result. state is shared by
+// the nested values decoded by the same call to decode.
+func (d *decoder) decodeValue(
+	offset uint,
+	result reflect.Value,
+	depth int,
+	state *decodeState,
+) (uint, error) {
 	if depth > maximumDataStructureDepth {
 		return 0, newInvalidDatabaseError(
 			"exceeded maximum data structure depth; database is likely corrupt",
@@ -51,12 +161,19 @@ func (d *decoder) decode(offset uint, result reflect.Value, depth int) (uint, er
 	if err != nil {
 		return 0, err
 	}
+	if d.profile != nil {
+		d.profile.add(typeNum)
+	}
 
 	if typeNum != _Pointer && result.Kind() == reflect.Uintptr {
 		result.Set(reflect.ValueOf(uintptr(offset)))
 		return d.nextValueOffset(offset, 1)
 	}
-	return d.decodeFromType(typeNum, size, newOffset, result, depth+1)
+	newOffset, err = d.decodeFromType(typeNum, size, newOffset, result, depth+1, state)
+	if err != nil && d.opts.reportValueErrors && !errors.As(err, new(*valueError)) {
+		err = &valueError{err: err, offset: offset, typeNum: typeNum}
+	}
+	return newOffset, err
 }
 
 func (d *decoder) decodeToDeserializer(
@@ -85,6 +202,9 @@ func (d *decoder) decodeToDeserializer(
 	if err != nil {
 		return 0, err
 	}
+	if d.profile != nil {
+		d.profile.add(typeNum)
+	}
 
 	return d.decodeFromTypeToDeserializer(typeNum, size, newOffset, dser, depth+1)
 }
@@ -93,7 +213,19 @@ func (d *decoder) decodePath(
 	offset uint,
 	path []any,
 	result reflect.Value,
+	addr netip.Addr,
 ) error {
+	offset, found, err := d.followPath(offset, path)
+	if err != nil || !found {
+		return err
+	}
+	_, err = d.decodeValue(offset, result, len(path), &decodeState{addr: addr})
+	return err
+}
+
+// followPath returns the offset of the value found by following path from
+// the value at offset. If the path does not exist, found is false.
+func (d *decoder) followPath(offset uint, path []any) (uint, bool, error) {
 PATH:
 	for i, v := range path {
 		var (
@@ -101,76 +233,183 @@ PATH:
 			size    uint
 			err     error
 		)
-		typeNum, size, offset, err = d.decodeCtrlData(offset)
+		typeNum, size, offset, err = d.decodeCtrlDataAndFollow(offset)
 		if err != nil {
-			return err
+			return 0, false, err
 		}
 
-		if typeNum == _Pointer {
-			pointer, _, err := d.decodePointer(size, offset)
-			if err != nil {
-				return err
-			}
-
-			typeNum, size, offset, err = d.decodeCtrlData(pointer)
-			if err != nil {
-				return err
-			}
+		if _, ok := v.(LastElement); ok {
+			v = -1
 		}
-
 		switch v := v.(type) {
 		case string:
 			// We are expecting a map
 			if typeNum != _Map {
 				// XXX - use type names in errors.
-				return fmt.Errorf("expected a map for %s but found %d", v, typeNum)
+				return 0, false, fmt.Errorf("expected a map for %s but found %d", v, typeNum)
 			}
 			for i := uint(0); i < size; i++ {
 				var key []byte
 				key, offset, err = d.decodeKey(offset)
 				if err != nil {
-					return err
+					return 0, false, err
 				}
 				if string(key) == v {
 					continue PATH
 				}
 				offset, err = d.nextValueOffset(offset, 1)
 				if err != nil {
-					return err
+					return 0, false, err
 				}
 			}
-			// Not found. Maybe return a boolean?
-			return nil
+			// Not found
+			return 0, false, nil
 		case int:
 			// We are expecting an array
 			if typeNum != _Slice {
 				// XXX - use type names in errors.
-				return fmt.Errorf("expected a slice for %d but found %d", v, typeNum)
+				return 0, false, fmt.Errorf("expected a slice for %d but found %d", v, typeNum)
 			}
 			var i uint
 			if v < 0 {
 				if size < uint(-v) {
 					// Slice is smaller than negative index, not found
-					return nil
+					return 0, false, nil
 				}
 				i = size - uint(-v)
 			} else {
 				if size <= uint(v) {
 					// Slice is smaller than index, not found
-					return nil
+					return 0, false, nil
 				}
 				i = uint(v)
 			}
 			offset, err = d.nextValueOffset(offset, i)
 			if err != nil {
-				return err
+				return 0, false, err
 			}
 		default:
-			return fmt.Errorf("unexpected type for %d value in path, %v: %T", i, v, v)
+			return 0, false, fmt.Errorf("unexpected type for %d value in path, %v: %T", i, v, v)
 		}
 	}
-	_, err := d.decode(offset, result, len(path))
-	return err
+	return offset, true, nil
+}
+
+// decodeMapKeys returns the keys of the map at offset without decoding the
+// values.
+func (d *decoder) decodeMapKeys(offset uint) ([]string, error) {
+	typeNum, size, offset, err := d.decodeCtrlDataAndFollow(offset)
+	if err != nil {
+		return nil, err
+	}
+	if typeNum != _Map {
+		return nil, fmt.Errorf("expected a map but found %d", typeNum)
+	}
+
+	keys := make([]string, 0, d.mapCapacity(size, offset))
+	for i := uint(0); i < size; i++ {
+		var key []byte
+		key, offset, err = d.decodeKey(offset)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, string(key))
+
+		offset, err = d.nextValueOffset(offset, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// appendInlined appends the encoding of the value at offset to dst, replacing
+// any pointers with the values they point to, so that the result may be
+// decoded without the rest of the data section. It returns the offset after
+// the value.
+func (d *decoder) appendInlined(dst []byte, offset uint, depth int) ([]byte, uint, error) {
+	if depth > maximumDataStructureDepth {
+		return nil, 0, newInvalidDatabaseError(
+			"exceeded maximum data structure depth; database is likely corrupt",
+		)
+	}
+	typeNum, size, newOffset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch typeNum {
+	case _Pointer:
+		pointer, newOffset, err := d.decodePointer(size, newOffset)
+		if err != nil {
+			return nil, 0, err
+		}
+		dst, _, err = d.appendInlined(dst, pointer, depth+1)
+		return dst, newOffset, err
+	case _Map, _Slice:
+		count := size
+		if typeNum == _Map {
+			count *= 2
+		}
+		dst = append(dst, d.buffer[offset:newOffset]...)
+		for i := uint(0); i < count; i++ {
+			dst, newOffset, err = d.appendInlined(dst, newOffset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return dst, newOffset, nil
+	case _Bool:
+		return append(dst, d.buffer[offset:newOffset]...), newOffset, nil
+	default:
+		end := newOffset + size
+		if end > uint(len(d.buffer)) {
+			return nil, 0, newOffsetError()
+		}
+		return append(dst, d.buffer[offset:end]...), end, nil
+	}
+}
+
+// peekEmpty reports whether the value at offset, following pointers, is a
+// map or array with no elements.
+func (d *decoder) peekEmpty(offset uint) (bool, error) {
+	typeNum, size, _, err := d.decodeCtrlDataAndFollow(offset)
+	if err != nil {
+		return false, err
+	}
+	return (typeNum == _Map || typeNum == _Slice) && size == 0, nil
+}
+
+// compareUint128 compares the uint128 at offset with the value whose high
+// and low 64 bits are hi and lo, returning -1, 0, or 1.
+func (d *decoder) compareUint128(offset uint, hi, lo uint64) (int, error) {
+	typeNum, size, offset, err := d.decodeCtrlDataAndFollow(offset)
+	if err != nil {
+		return 0, err
+	}
+	if typeNum != _Uint128 {
+		return 0, fmt.Errorf("expected a uint128 but found %d", typeNum)
+	}
+	if size > 16 {
+		return 0, newInvalidDatabaseError(
+			"the MaxMind DB file's data section contains bad data (uint128 size of %v)",
+			size,
+		)
+	}
+	newOffset := offset + size
+	if newOffset > uint(len(d.buffer)) {
+		return 0, newOffsetError()
+	}
+
+	var valueHi, valueLo uint64
+	for _, b := range d.buffer[offset:newOffset] {
+		valueHi = valueHi<<8 | valueLo>>56
+		valueLo = valueLo<<8 | uint64(b)
+	}
+
+	if valueHi != hi {
+		return cmp.Compare(valueHi, hi), nil
+	}
+	return cmp.Compare(valueLo, lo), nil
 }
 
 func (d *decoder) decodeCtrlData(offset uint) (dataType, uint, uint, error) {
@@ -194,6 +433,21 @@ func (d *decoder) decodeCtrlData(offset uint) (dataType, uint, uint, error) {
 	return typeNum, size, newOffset, err
 }
 
+// decodeCtrlDataAndFollow is like decodeCtrlData except that, if the value
+// at offset is a pointer, the control data of the value it points to is
+// returned instead.
+func (d *decoder) decodeCtrlDataAndFollow(offset uint) (dataType, uint, uint, error) {
+	typeNum, size, newOffset, err := d.decodeCtrlData(offset)
+	if err != nil || typeNum != _Pointer {
+		return typeNum, size, newOffset, err
+	}
+	pointer, _, err := d.decodePointer(size, newOffset)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return d.decodeCtrlData(pointer)
+}
+
 func (d *decoder) sizeFromCtrlByte(
 	ctrlByte byte,
 	offset uint,
@@ -235,6 +489,7 @@ func (d *decoder) decodeFromType(
 	offset uint,
 	result reflect.Value,
 	depth int,
+	state *decodeState,
 ) (uint, error) {
 	result = indirect(result)
 
@@ -243,11 +498,11 @@ func (d *decoder) decodeFromType(
 	case _Bool:
 		return unmarshalBool(size, offset, result)
 	case _Map:
-		return d.unmarshalMap(size, offset, result, depth)
+		return d.unmarshalMap(size, offset, result, depth, state)
 	case _Pointer:
-		return d.unmarshalPointer(size, offset, result, depth)
+		return d.unmarshalPointer(size, offset, result, depth, state)
 	case _Slice:
-		return d.unmarshalSlice(size, offset, result, depth)
+		return d.unmarshalSlice(size, offset, result, depth, state)
 	}
 
 	// For the remaining types, size is the byte size
@@ -396,6 +651,14 @@ var sliceType = reflect.TypeOf([]byte{})
 func (d *decoder) unmarshalBytes(size, offset uint, result reflect.Value) (uint, error) {
 	value, newOffset := d.decodeBytes(size, offset)
 
+	// Types such as protocol buffer messages stored in their serialized form
+	// may implement encoding.BinaryUnmarshaler to decode the bytes
+	// themselves.
+	if result.CanAddr() && binaryUnmarshalers.pointerImplements(result.Type()) {
+		u := result.Addr().Interface().(encoding.BinaryUnmarshaler)
+		return newOffset, u.UnmarshalBinary(value)
+	}
+
 	switch result.Kind() {
 	case reflect.Slice:
 		if result.Type() == sliceType {
@@ -426,6 +689,10 @@ func (d *decoder) unmarshalFloat32(size, offset uint, result reflect.Value) (uin
 		return newOffset, nil
 	case reflect.Interface:
 		if result.NumMethod() == 0 {
+			if d.opts.floatAsString {
+				result.Set(reflect.ValueOf(strconv.FormatFloat(float64(value), 'g', -1, 32)))
+				return newOffset, nil
+			}
 			result.Set(reflect.ValueOf(value))
 			return newOffset, nil
 		}
@@ -451,6 +718,10 @@ func (d *decoder) unmarshalFloat64(size, offset uint, result reflect.Value) (uin
 		return newOffset, nil
 	case reflect.Interface:
 		if result.NumMethod() == 0 {
+			if d.opts.floatAsString {
+				result.Set(reflect.ValueOf(strconv.FormatFloat(value, 'g', -1, 64)))
+				return newOffset, nil
+			}
 			result.Set(reflect.ValueOf(value))
 			return newOffset, nil
 		}
@@ -499,19 +770,24 @@ func (d *decoder) unmarshalMap(
 	offset uint,
 	result reflect.Value,
 	depth int,
+	state *decodeState,
 ) (uint, error) {
 	result = indirect(result)
 	switch result.Kind() {
 	default:
 		return 0, newUnmarshalTypeStrError("map", result.Type())
 	case reflect.Struct:
-		return d.decodeStruct(size, offset, result, depth)
+		return d.decodeStruct(size, offset, result, depth, state)
 	case reflect.Map:
-		return d.decodeMap(size, offset, result, depth)
+		return d.decodeMap(size, offset, result, depth, state)
 	case reflect.Interface:
 		if result.NumMethod() == 0 {
-			rv := reflect.ValueOf(make(map[string]any, size))
-			newOffset, err := d.decodeMap(size, offset, rv, depth)
+			if err := d.enterAny(state); err != nil {
+				return 0, err
+			}
+			rv := reflect.ValueOf(make(map[string]any, d.mapCapacity(size, offset)))
+			newOffset, err := d.decodeMap(size, offset, rv, depth, state)
+			state.anyDepth--
 			result.Set(rv)
 			return newOffset, err
 		}
@@ -519,33 +795,75 @@ func (d *decoder) unmarshalMap(
 	}
 }
 
+// enterAny records that a map or array is being decoded into an empty
+// interface and returns an error if this exceeds the WithMaxDecodeDepth
+// limit.
+func (d *decoder) enterAny(state *decodeState) error {
+	state.anyDepth++
+	if d.opts.maxDecodeDepth > 0 && state.anyDepth > d.opts.maxDecodeDepth {
+		return fmt.Errorf(
+			"exceeded maximum decode depth of %d when decoding into an interface",
+			d.opts.maxDecodeDepth,
+		)
+	}
+	return nil
+}
+
 func (d *decoder) unmarshalPointer(
 	size, offset uint,
 	result reflect.Value,
 	depth int,
+	state *decodeState,
 ) (uint, error) {
 	pointer, newOffset, err := d.decodePointer(size, offset)
 	if err != nil {
 		return 0, err
 	}
-	_, err = d.decode(pointer, result, depth)
+	if d.opts.disablePointerFollowing {
+		return newOffset, unmarshalPointerTarget(pointer, result)
+	}
+	_, err = d.decodeValue(pointer, result, depth, state)
 	return newOffset, err
 }
 
+// unmarshalPointerTarget stores the offset a pointer points to rather than
+// the value at that offset. This is only used with WithoutPointerFollowing.
+func unmarshalPointerTarget(pointer uint, result reflect.Value) error {
+	value := uintptr(pointer)
+	switch result.Kind() {
+	case reflect.Uintptr:
+		result.SetUint(uint64(value))
+		return nil
+	case reflect.Interface:
+		if result.NumMethod() == 0 {
+			result.Set(reflect.ValueOf(value))
+			return nil
+		}
+	}
+	return newUnmarshalTypeError(value, result.Type())
+}
+
 func (d *decoder) unmarshalSlice(
 	size uint,
 	offset uint,
 	result reflect.Value,
 	depth int,
+	state *decodeState,
 ) (uint, error) {
 	switch result.Kind() {
 	case reflect.Slice:
-		return d.decodeSlice(size, offset, result, depth)
+		return d.decodeSlice(size, offset, result, depth, state)
+	case reflect.Struct:
+		return d.decodeSliceToStruct(size, offset, result, depth, state)
 	case reflect.Interface:
 		if result.NumMethod() == 0 {
+			if err := d.enterAny(state); err != nil {
+				return 0, err
+			}
 			a := []any{}
 			rv := reflect.ValueOf(&a).Elem()
-			newOffset, err := d.decodeSlice(size, offset, rv, depth)
+			newOffset, err := d.decodeSlice(size, offset, rv, depth, state)
+			state.anyDepth--
 			result.Set(rv)
 			return newOffset, err
 		}
@@ -553,13 +871,90 @@ func (d *decoder) unmarshalSlice(
 	return 0, newUnmarshalTypeStrError("array", result.Type())
 }
 
+var (
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+
+	textUnmarshalers   = &implementsCache{iface: textUnmarshalerType}
+	binaryUnmarshalers = &implementsCache{iface: binaryUnmarshalerType}
+)
+
+// implementsCache records whether pointers to each type implement iface.
+// The check is cached as it is made for every string or bytes value decoded,
+// which is too often to repeat reflect.PointerTo and Implements.
+type implementsCache struct {
+	iface reflect.Type
+	types sync.Map
+}
+
+// pointerImplements reports whether *t implements the interface.
+func (c *implementsCache) pointerImplements(t reflect.Type) bool {
+	if ok, found := c.types.Load(t); found {
+		return ok.(bool)
+	}
+	ok := reflect.PointerTo(t).Implements(c.iface)
+	c.types.Store(t, ok)
+	return ok
+}
+
 func (d *decoder) unmarshalString(size, offset uint, result reflect.Value) (uint, error) {
 	value, newOffset := d.decodeString(size, offset)
 
+	// netip.Addr and netip.Prefix implement encoding.TextUnmarshaler, but
+	// they accept an empty string and do not report the type error that
+	// other mismatched values do.
+	switch result.Type() {
+	case addrType:
+		ip, err := netip.ParseAddr(value)
+		if err != nil {
+			return newOffset, newUnmarshalTypeError(value, result.Type())
+		}
+		result.Set(reflect.ValueOf(ip))
+		return newOffset, nil
+	case prefixType:
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return newOffset, newUnmarshalTypeError(value, result.Type())
+		}
+		result.Set(reflect.ValueOf(prefix))
+		return newOffset, nil
+	}
+
+	// Types such as enums may implement encoding.TextUnmarshaler to
+	// convert the string themselves. This also applies to slice elements
+	// and to map values, which are decoded into an addressable temporary
+	// value before being stored in the map.
+	if result.CanAddr() && textUnmarshalers.pointerImplements(result.Type()) {
+		u := result.Addr().Interface().(encoding.TextUnmarshaler)
+		return newOffset, u.UnmarshalText([]byte(value))
+	}
+
+	// An interface with methods cannot hold a string, but it may hold a
+	// value of a type that implements encoding.TextUnmarshaler, in which
+	// case the value is replaced with a new one of the same type.
+	if result.Kind() == reflect.Interface && result.NumMethod() > 0 && !result.IsNil() {
+		elemType := result.Elem().Type()
+		if elemType.Kind() != reflect.Ptr && textUnmarshalers.pointerImplements(elemType) {
+			v := reflect.New(elemType)
+			if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+				return newOffset, err
+			}
+			result.Set(v.Elem())
+			return newOffset, nil
+		}
+	}
+
 	switch result.Kind() {
 	case reflect.String:
 		result.SetString(value)
 		return newOffset, nil
+	case reflect.Slice:
+		// The raw bytes are stored without checking that they are valid
+		// UTF-8.
+		if result.Type() == sliceType {
+			result.SetBytes([]byte(value))
+			return newOffset, nil
+		}
 	case reflect.Interface:
 		if result.NumMethod() == 0 {
 			result.Set(reflect.ValueOf(value))
@@ -601,6 +996,11 @@ func (d *decoder) unmarshalUint(
 			result.SetUint(value)
 			return newOffset, nil
 		}
+	case reflect.Struct:
+		if result.Type() == timeType && value <= math.MaxInt64 {
+			result.Set(reflect.ValueOf(time.Unix(int64(value), 0)))
+			return newOffset, nil
+		}
 	case reflect.Interface:
 		if result.NumMethod() == 0 {
 			result.Set(reflect.ValueOf(value))
@@ -610,7 +1010,10 @@ func (d *decoder) unmarshalUint(
 	return newOffset, newUnmarshalTypeError(value, result.Type())
 }
 
-var bigIntType = reflect.TypeOf(big.Int{})
+var (
+	bigIntType = reflect.TypeOf(big.Int{})
+	timeType   = reflect.TypeFor[time.Time]()
+)
 
 func (d *decoder) unmarshalUint128(size, offset uint, result reflect.Value) (uint, error) {
 	if size > 16 {
@@ -632,6 +1035,16 @@ func (d *decoder) unmarshalUint128(size, offset uint, result reflect.Value) (uin
 			result.Set(reflect.ValueOf(value))
 			return newOffset, nil
 		}
+	case reflect.Array:
+		if result.Len() == 16 && result.Type().Elem().Kind() == reflect.Uint8 {
+			var b [16]byte
+			value.FillBytes(b[:])
+			result.Set(reflect.ValueOf(b).Convert(result.Type()))
+			return newOffset, nil
+		}
+	case reflect.String:
+		result.SetString(value.String())
+		return newOffset, nil
 	}
 	return newOffset, newUnmarshalTypeError(value, result.Type())
 }
@@ -668,16 +1081,40 @@ func (d *decoder) decodeInt(size, offset uint) (int, uint) {
 	return int(val), newOffset
 }
 
+// mapCapacity returns the number of entries to preallocate for a map of size
+// entries starting at offset. Each entry takes at least two bytes, one for the
+// key and one for the value, so a corrupt size larger than the rest of the
+// buffer could hold is capped rather than causing a huge allocation before
+// the end of the buffer is reached.
+func (d *decoder) mapCapacity(size, offset uint) int {
+	if offset > uint(len(d.buffer)) {
+		return 0
+	}
+	return int(min(size, (uint(len(d.buffer))-offset)/2))
+}
+
+// sliceCapacity returns the number of elements to preallocate for an array
+// of size elements starting at offset. Each element takes at least one byte,
+// so the capacity is capped as with mapCapacity.
+func (d *decoder) sliceCapacity(size, offset uint) int {
+	if offset > uint(len(d.buffer)) {
+		return 0
+	}
+	return int(min(size, uint(len(d.buffer))-offset))
+}
+
 func (d *decoder) decodeMap(
 	size uint,
 	offset uint,
 	result reflect.Value,
 	depth int,
+	state *decodeState,
 ) (uint, error) {
 	if result.IsNil() {
-		result.Set(reflect.MakeMapWithSize(result.Type(), int(size)))
+		result.Set(reflect.MakeMapWithSize(result.Type(), d.mapCapacity(size, offset)))
 	}
 
+	skipKeys := state.takeSkipKeys()
 	mapType := result.Type()
 	keyValue := reflect.New(mapType.Key()).Elem()
 	elemType := mapType.Elem()
@@ -689,6 +1126,13 @@ func (d *decoder) decodeMap(
 		if err != nil {
 			return 0, err
 		}
+		if isSkipKey(skipKeys, key) {
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
 
 		if elemValue.IsValid() {
 			elemValue.SetZero()
@@ -696,7 +1140,7 @@ func (d *decoder) decodeMap(
 			elemValue = reflect.New(elemType).Elem()
 		}
 
-		offset, err = d.decode(offset, elemValue, depth)
+		offset, err = d.decodeValue(offset, elemValue, depth, state)
 		if err != nil {
 			return 0, fmt.Errorf("decoding value for %s: %w", key, err)
 		}
@@ -707,6 +1151,23 @@ func (d *decoder) decodeMap(
 	return offset, nil
 }
 
+// takeSkipKeys returns the keys set by Result.DecodeExcept and clears them
+// so that they are not applied to nested maps.
+func (s *decodeState) takeSkipKeys() []string {
+	skipKeys := s.skipKeys
+	s.skipKeys = nil
+	return skipKeys
+}
+
+func isSkipKey(skipKeys []string, key []byte) bool {
+	for _, skipKey := range skipKeys {
+		if skipKey == string(key) {
+			return true
+		}
+	}
+	return false
+}
+
 func (d *decoder) decodeMapToDeserializer(
 	size uint,
 	offset uint,
@@ -718,8 +1179,15 @@ func (d *decoder) decodeMapToDeserializer(
 		return 0, err
 	}
 	for i := uint(0); i < size; i++ {
-		// TODO - implement key/value skipping?
-		offset, err = d.decodeToDeserializer(offset, dser, depth, true)
+		// Keys are always emitted. Only values are passed to ShouldSkip as
+		// skipping a key would leave the deserializer unable to pair the
+		// following value with it.
+		var key []byte
+		key, offset, err = d.decodeKey(offset)
+		if err != nil {
+			return 0, err
+		}
+		err = dser.String(string(key))
 		if err != nil {
 			return 0, err
 		}
@@ -776,11 +1244,12 @@ func (d *decoder) decodeSlice(
 	offset uint,
 	result reflect.Value,
 	depth int,
+	state *decodeState,
 ) (uint, error) {
 	result.Set(reflect.MakeSlice(result.Type(), int(size), int(size)))
 	for i := 0; i < int(size); i++ {
 		var err error
-		offset, err = d.decode(offset, result.Index(i), depth)
+		offset, err = d.decodeValue(offset, result.Index(i), depth, state)
 		if err != nil {
 			return 0, err
 		}
@@ -813,7 +1282,11 @@ func (d *decoder) decodeSliceToDeserializer(
 
 func (d *decoder) decodeString(size, offset uint) (string, uint) {
 	newOffset := offset + size
-	return string(d.buffer[offset:newOffset]), newOffset
+	value := string(d.buffer[offset:newOffset])
+	if d.opts.trimStrings {
+		value = strings.TrimSpace(value)
+	}
+	return value, newOffset
 }
 
 func (d *decoder) decodeStruct(
@@ -821,30 +1294,63 @@ func (d *decoder) decodeStruct(
 	offset uint,
 	result reflect.Value,
 	depth int,
+	state *decodeState,
 ) (uint, error) {
-	fields := cachedFields(result)
+	fields, err := cachedFields(result, d.opts.untaggedLowercase)
+	if err != nil {
+		return 0, err
+	}
+	for _, warning := range fields.warnings {
+		d.opts.logger.log(warning)
+	}
+
+	skipKeys := state.takeSkipKeys()
 
 	// This fills in embedded structs
 	for _, i := range fields.anonymousFields {
-		_, err := d.unmarshalMap(size, offset, result.Field(i), depth)
+		state.skipKeys = skipKeys
+		_, err := d.unmarshalMap(size, offset, result.Field(i), depth, state)
 		if err != nil {
 			return 0, err
 		}
 	}
 
+	for _, i := range fields.addrFields {
+		result.Field(i).Set(reflect.ValueOf(state.addr))
+	}
+
+	var rawMap reflect.Value
+	if fields.rawField >= 0 {
+		rawMap = result.Field(fields.rawField)
+		if rawMap.IsNil() {
+			rawMap.Set(reflect.MakeMapWithSize(rawMapType, d.mapCapacity(size, offset)))
+		}
+	}
+
 	// This handles named fields
+	var fieldErrs []error
 	for i := uint(0); i < size; i++ {
-		var (
-			err error
-			key []byte
-		)
+		var key []byte
 		key, offset, err = d.decodeKey(offset)
 		if err != nil {
 			return 0, err
 		}
-		// The string() does not create a copy due to this compiler
-		// optimization: https://github.com/golang/go/issues/3512
-		j, ok := fields.namedFields[string(key)]
+		if isSkipKey(skipKeys, key) {
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if rawMap.IsValid() {
+			var raw []byte
+			raw, _, err = d.appendInlined(nil, offset, depth)
+			if err != nil {
+				return 0, err
+			}
+			rawMap.SetMapIndex(reflect.ValueOf(string(key)), reflect.ValueOf(raw))
+		}
+		field, ok := fields.namedField(key)
 		if !ok {
 			offset, err = d.nextValueOffset(offset, 1)
 			if err != nil {
@@ -853,50 +1359,498 @@ func (d *decoder) decodeStruct(
 			continue
 		}
 
-		offset, err = d.decode(offset, result.Field(j), depth)
+		offset, fieldErrs, err = d.decodeStructField(offset, result, field, key, fieldErrs, depth, state)
 		if err != nil {
-			return 0, fmt.Errorf("decoding value for %s: %w", key, err)
+			return 0, err
 		}
 	}
-	return offset, nil
+	return offset, errors.Join(fieldErrs...)
+}
+
+// decodeSliceToStruct decodes an array into a struct with positional fields,
+// i.e., fields tagged with an array index such as `maxminddb:"[0]"`. Elements
+// without a corresponding field are skipped.
+func (d *decoder) decodeSliceToStruct(
+	size uint,
+	offset uint,
+	result reflect.Value,
+	depth int,
+	state *decodeState,
+) (uint, error) {
+	fields, err := cachedFields(result, d.opts.untaggedLowercase)
+	if err != nil {
+		return 0, err
+	}
+	for _, warning := range fields.warnings {
+		d.opts.logger.log(warning)
+	}
+	if len(fields.positionalFields) == 0 {
+		return 0, newUnmarshalTypeStrError("array", result.Type())
+	}
+
+	var fieldErrs []error
+	for i := uint(0); i < size; i++ {
+		field, ok := fields.positionalFields[i]
+		if !ok {
+			offset, err = d.nextValueOffset(offset, 1)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		key := []byte("[" + strconv.FormatUint(uint64(i), 10) + "]")
+		offset, fieldErrs, err = d.decodeStructField(offset, result, field, key, fieldErrs, depth, state)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return offset, errors.Join(fieldErrs...)
+}
+
+// decodeStructField decodes the value at offset into the struct field. When
+// WithCollectErrors is used, type mismatches are added to fieldErrs rather
+// than returned and the field is left as its zero value.
+func (d *decoder) decodeStructField(
+	offset uint,
+	result reflect.Value,
+	field fieldInfo,
+	key []byte,
+	fieldErrs []error,
+	depth int,
+	state *decodeState,
+) (uint, []error, error) {
+	valueOffset := offset
+	fieldValue := result.Field(field.index)
+
+	var err error
+	switch {
+	case field.offset:
+		offset, err = d.decodeOffset(offset, fieldValue)
+	case field.scale != 0:
+		offset, err = d.decodeScaled(offset, fieldValue, field.scale, depth, state)
+	case field.fromString:
+		offset, err = d.decodeFromString(offset, fieldValue, depth, state)
+	default:
+		offset, err = d.decodeValue(offset, fieldValue, depth, state)
+	}
+	if err == nil {
+		return offset, fieldErrs, nil
+	}
+	if !d.opts.collectErrors || !isCollectableError(err) {
+		return 0, fieldErrs, fmt.Errorf("decoding value for %s: %w", key, err)
+	}
+	fieldErrs = appendFieldErrors(fieldErrs, key, err)
+	// Errors collected from a nested struct are for its fields, which have
+	// already been zeroed. Its other fields were decoded and are kept.
+	if _, nested := err.(interface{ Unwrap() []error }); !nested {
+		fieldValue.SetZero()
+	}
+	offset, err = d.nextValueOffset(valueOffset, 1)
+	return offset, fieldErrs, err
+}
+
+// isCollectableError returns true if decoding may continue with the next
+// field after err when WithCollectErrors is used. This is the case for type
+// mismatches, but not for invalid data.
+func isCollectableError(err error) bool {
+	var typeErr UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		var dbErr InvalidDatabaseError
+		return !errors.As(err, &dbErr)
+	}
+	return false
+}
+
+// appendFieldErrors adds err, prefixed with the key, to errs. Errors already
+// collected from nested structs are flattened so that each retains its full
+// path.
+func appendFieldErrors(errs []error, key []byte, err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, fmt.Errorf("decoding value for %s: %w", key, e))
+		}
+		return errs
+	}
+	return append(errs, fmt.Errorf("decoding value for %s: %w", key, err))
+}
+
+// decodeOffset stores the offset of the value at offset in result, a uintptr,
+// for the offset tag option. If the value is a pointer, the offset it points
+// to is stored instead, so the offset may be passed to Reader.LookupOffset.
+func (d *decoder) decodeOffset(offset uint, result reflect.Value) (uint, error) {
+	typeNum, size, newOffset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return 0, err
+	}
+	target := offset
+	if typeNum == _Pointer {
+		target, _, err = d.decodePointer(size, newOffset)
+		if err != nil {
+			return 0, err
+		}
+	}
+	result.SetUint(uint64(target))
+	return d.nextValueOffset(offset, 1)
+}
+
+// decodeScaled decodes a floating point value, multiplies it by scale, and
+// stores the result in an integer or floating point destination. This is used
+// for fields with the scale tag option.
+func (d *decoder) decodeScaled(
+	offset uint,
+	result reflect.Value,
+	scale float64,
+	depth int,
+	state *decodeState,
+) (uint, error) {
+	var value float64
+	newOffset, err := d.decodeValue(offset, reflect.ValueOf(&value), depth, state)
+	if err != nil {
+		return 0, err
+	}
+	scaled := value * scale
+
+	result = indirect(result)
+	switch result.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := int64(math.Round(scaled))
+		if !result.OverflowInt(n) {
+			result.SetInt(n)
+			return newOffset, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if scaled >= 0 {
+			n := uint64(math.Round(scaled))
+			if !result.OverflowUint(n) {
+				result.SetUint(n)
+				return newOffset, nil
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		if !result.OverflowFloat(scaled) {
+			result.SetFloat(scaled)
+			return newOffset, nil
+		}
+	}
+	return newOffset, newUnmarshalTypeError(scaled, result.Type())
+}
+
+// decodeFromString decodes a number stored as a string into result, which
+// must be an integer or float. Values that are not strings are decoded as
+// usual.
+func (d *decoder) decodeFromString(offset uint, result reflect.Value, depth int, state *decodeState) (uint, error) {
+	typeNum, _, _, err := d.decodeCtrlDataAndFollow(offset)
+	if err != nil {
+		return 0, err
+	}
+	if typeNum != _String {
+		return d.decodeValue(offset, result, depth, state)
+	}
+
+	var value string
+	newOffset, err := d.decodeValue(offset, reflect.ValueOf(&value), depth, state)
+	if err != nil {
+		return 0, err
+	}
+
+	result = indirect(result)
+	switch result.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, result.Type().Bits())
+		if err == nil {
+			result.SetInt(n)
+			return newOffset, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, result.Type().Bits())
+		if err == nil {
+			result.SetUint(n)
+			return newOffset, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, result.Type().Bits())
+		if err == nil {
+			result.SetFloat(f)
+			return newOffset, nil
+		}
+	}
+	return newOffset, newUnmarshalTypeError(value, result.Type())
+}
+
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// derefType returns the type that t points to, following any number of
+// pointers.
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
 }
 
 type fieldsType struct {
-	namedFields     map[string]int
-	anonymousFields []int
+	namedFields map[string]fieldInfo
+	// namedIndex is used instead of namedFields to look up fields by name
+	// if the struct has many named fields. It is nil otherwise.
+	namedIndex *fieldIndex
+	// positionalFields maps array indexes to the fields tagged with them,
+	// e.g., `maxminddb:"[0]"`.
+	positionalFields map[uint]fieldInfo
+	anonymousFields  []int
+	// rawField is the index of the field with the raw tag option, or -1 if
+	// there is none.
+	rawField int
+	// addrFields are the indexes of the fields with the addr tag option.
+	addrFields []int
+	// err is set if a struct tag could not be parsed. It is returned when
+	// decoding into the struct.
+	err error
+	// warnings describes tag problems that do not prevent decoding, such as
+	// unknown options. They are reported to the WithLogger function.
+	warnings []string
+}
+
+// namedField returns the field named key.
+func (f *fieldsType) namedField(key []byte) (fieldInfo, bool) {
+	if f.namedIndex != nil {
+		return f.namedIndex.lookup(key)
+	}
+	// The string() does not create a copy due to this compiler
+	// optimization: https://github.com/golang/go/issues/3512
+	field, ok := f.namedFields[string(key)]
+	return field, ok
+}
+
+type fieldInfo struct {
+	index int
+	// scale is the multiplier from the scale tag option. It is 0 if the
+	// option was not set.
+	scale float64
+	// offset is set by the offset tag option.
+	offset bool
+	// fromString is set by the string tag option.
+	fromString bool
 }
 
 var fieldsMap sync.Map
 
-func cachedFields(result reflect.Value) *fieldsType {
+// fieldsKey is the key for fieldsMap. The fields of a type depend on the
+// WithUntaggedLowercase option as well as the type.
+type fieldsKey struct {
+	resultType        reflect.Type
+	untaggedLowercase bool
+}
+
+func cachedFields(result reflect.Value, untaggedLowercase bool) (*fieldsType, error) {
 	resultType := result.Type()
+	key := fieldsKey{resultType, untaggedLowercase}
 
-	if fields, ok := fieldsMap.Load(resultType); ok {
-		return fields.(*fieldsType)
+	if fields, ok := fieldsMap.Load(key); ok {
+		f := fields.(*fieldsType)
+		return f, f.err
 	}
 	numFields := resultType.NumField()
-	namedFields := make(map[string]int, numFields)
+	namedFields := make(map[string]fieldInfo, numFields)
+	var positionalFields map[uint]fieldInfo
 	var anonymous []int
+	rawField := -1
+	var addrFields []int
+	var tagErr error
+	var warnings []string
 	for i := 0; i < numFields; i++ {
 		field := resultType.Field(i)
 
 		fieldName := field.Name
+		if untaggedLowercase {
+			fieldName = strings.ToLower(fieldName)
+		}
+		info := fieldInfo{index: i}
 		if tag := field.Tag.Get("maxminddb"); tag != "" {
 			if tag == "-" {
 				continue
 			}
-			fieldName = tag
+			name, options, err := parseTag(tag)
+			if err != nil && tagErr == nil {
+				tagErr = fmt.Errorf("invalid maxminddb tag on field %s of %s: %w", field.Name, resultType, err)
+			}
+			if name != "" {
+				fieldName = name
+			}
+			info.scale = options.scale
+			info.offset = options.offset
+			info.fromString = options.fromString
+			if options.fromString && !isNumericKind(derefType(field.Type).Kind()) && tagErr == nil {
+				tagErr = fmt.Errorf(
+					"invalid maxminddb tag on field %s of %s: string requires an integer or float",
+					field.Name,
+					resultType,
+				)
+			}
+			if options.offset && field.Type.Kind() != reflect.Uintptr && tagErr == nil {
+				tagErr = fmt.Errorf(
+					"invalid maxminddb tag on field %s of %s: offset requires a uintptr",
+					field.Name,
+					resultType,
+				)
+			}
+			if options.addr {
+				if field.Type != addrType && tagErr == nil {
+					tagErr = fmt.Errorf(
+						"invalid maxminddb tag on field %s of %s: addr requires a netip.Addr",
+						field.Name,
+						resultType,
+					)
+				}
+				addrFields = append(addrFields, i)
+				continue
+			}
+			if options.raw {
+				if field.Type != rawMapType && tagErr == nil {
+					tagErr = fmt.Errorf(
+						"invalid maxminddb tag on field %s of %s: raw requires a map[string][]byte",
+						field.Name,
+						resultType,
+					)
+				}
+				rawField = i
+				continue
+			}
+			for _, option := range options.unknown {
+				warnings = append(warnings, fmt.Sprintf(
+					"ignoring unknown option %q in maxminddb tag on field %s of %s",
+					option,
+					field.Name,
+					resultType,
+				))
+			}
 		}
 		if field.Anonymous {
 			anonymous = append(anonymous, i)
 			continue
 		}
-		namedFields[fieldName] = i
+		if position, ok, err := parsePosition(fieldName); ok {
+			if err != nil && tagErr == nil {
+				tagErr = fmt.Errorf("invalid maxminddb tag on field %s of %s: %w", field.Name, resultType, err)
+			}
+			if positionalFields == nil {
+				positionalFields = map[uint]fieldInfo{}
+			}
+			positionalFields[position] = info
+			continue
+		}
+		namedFields[fieldName] = info
 	}
-	fields := &fieldsType{namedFields, anonymous}
-	fieldsMap.Store(resultType, fields)
+	var namedIndex *fieldIndex
+	if len(namedFields) >= minFieldIndexFields {
+		namedIndex = newFieldIndex(namedFields)
+	}
+	fields := &fieldsType{
+		namedFields:      namedFields,
+		namedIndex:       namedIndex,
+		positionalFields: positionalFields,
+		anonymousFields:  anonymous,
+		rawField:         rawField,
+		addrFields:       addrFields,
+		err:              tagErr,
+		warnings:         warnings,
+	}
+	fieldsMap.Store(key, fields)
+
+	return fields, tagErr
+}
+
+var (
+	rawMapType = reflect.TypeFor[map[string][]byte]()
+	addrType   = reflect.TypeFor[netip.Addr]()
+	prefixType = reflect.TypeFor[netip.Prefix]()
+)
 
-	return fields
+type tagOptions struct {
+	// raw is set by the raw option, which captures the encoded value for
+	// each key of the map.
+	raw   bool
+	scale float64
+	// offset is set by the offset option, which stores the offset of the
+	// value rather than decoding it.
+	offset bool
+	// addr is set by the addr option, which stores the Result's IP address.
+	addr bool
+	// fromString is set by the string option, which parses numbers stored as
+	// strings.
+	fromString bool
+	// unknown holds the options that were not recognized.
+	unknown []string
+}
+
+// parseTag splits a maxminddb struct tag into the key name and the options
+// following it, e.g., "latitude,scale=10000000". Unknown options are
+// ignored.
+func parseTag(tag string) (string, tagOptions, error) {
+	var options tagOptions
+	name, rest, _ := strings.Cut(tag, ",")
+	for rest != "" {
+		var option string
+		option, rest, _ = strings.Cut(rest, ",")
+
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "addr":
+			options.addr = true
+		case "offset":
+			options.offset = true
+		case "omitempty":
+			// This is accepted so that tags may be shared with
+			// encoding/json. It has no effect on decoding.
+		case "raw":
+			options.raw = true
+		case "string":
+			options.fromString = true
+		case "scale":
+			scale, err := strconv.ParseFloat(value, 64)
+			if err != nil || scale == 0 {
+				return name, options, fmt.Errorf("invalid scale %q", value)
+			}
+			options.scale = scale
+		default:
+			options.unknown = append(options.unknown, option)
+		}
+	}
+	// These fields do not correspond to a key, so a key name would be
+	// ignored.
+	if options.addr && name != "" {
+		return name, options, fmt.Errorf("addr cannot be used with the key %q", name)
+	}
+	if options.raw && name != "" {
+		return name, options, fmt.Errorf("raw cannot be used with the key %q", name)
+	}
+	return name, options, nil
+}
+
+// parsePosition parses a positional tag name such as "[0]". ok is false if
+// the name is not in that form.
+func parsePosition(name string) (position uint, ok bool, err error) {
+	inner, ok := strings.CutPrefix(name, "[")
+	if !ok {
+		return 0, false, nil
+	}
+	inner, ok = strings.CutSuffix(inner, "]")
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := strconv.ParseUint(inner, 10, 0)
+	if err != nil {
+		return 0, true, fmt.Errorf("invalid position %q", name)
+	}
+	return uint(n), true, nil
 }
 
 func (d *decoder) decodeUint(size, offset uint) (uint64, uint) {
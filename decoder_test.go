@@ -1,13 +1,18 @@
 package maxminddb
 
 import (
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
 	"math/big"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -193,6 +198,96 @@ func TestUint128(t *testing.T) {
 	validateDecoding(t, uints)
 }
 
+func TestDecodingUint128ToBigInt(t *testing.T) {
+	tests := map[string]*big.Int{
+		"0003":     big.NewInt(0),
+		"020301f4": big.NewInt(500),
+	}
+	for i := 1; i <= 16; i++ {
+		expected := new(big.Int).Lsh(big.NewInt(1), uint(8*i))
+		expected.Sub(expected, big.NewInt(1))
+		tests[fmt.Sprintf("%02x03%s", i, strings.Repeat("ff", i))] = expected
+	}
+
+	for input, expected := range tests {
+		inputBytes, err := hex.DecodeString(input)
+		require.NoError(t, err)
+		d := decoder{buffer: inputBytes}
+
+		var value big.Int
+		offset, err := d.decode(0, reflect.ValueOf(&value), 0)
+		require.NoError(t, err)
+		assert.Equal(t, uint(len(inputBytes)), offset, input)
+		assert.Equal(t, 0, expected.Cmp(&value), input)
+
+		var pointer *big.Int
+		_, err = d.decode(0, reflect.ValueOf(&pointer), 0)
+		require.NoError(t, err)
+		assert.Equal(t, 0, expected.Cmp(pointer), input)
+	}
+}
+
+func TestCompareUint128(t *testing.T) {
+	tests := []struct {
+		input    string
+		hi, lo   uint64
+		expected int
+	}{
+		{"0003", 0, 0, 0},
+		{"0003", 0, 1, -1},
+		{"0203" + "01f4", 0, 500, 0},
+		{"0203" + "01f4", 0, 499, 1},
+		{"0203" + "01f4", 1, 0, -1},
+		{"0903" + "010000000000000000", 1, 0, 0},
+		{"0903" + "010000000000000000", 0, math.MaxUint64, 1},
+		{"1003" + strings.Repeat("ff", 16), math.MaxUint64, math.MaxUint64, 0},
+		{"1003" + strings.Repeat("ff", 16), math.MaxUint64, math.MaxUint64 - 1, 1},
+		{"1003" + "7f" + strings.Repeat("ff", 15), math.MaxUint64, 0, -1},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s-%d-%d", test.input, test.hi, test.lo), func(t *testing.T) {
+			inputBytes, err := hex.DecodeString(test.input)
+			require.NoError(t, err)
+			d := decoder{buffer: inputBytes}
+
+			actual, err := d.compareUint128(0, test.hi, test.lo)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+
+	// A uint16 is not a uint128.
+	d := decoder{buffer: []byte{0xa1, 0x01}}
+	_, err := d.compareUint128(0, 0, 1)
+	require.Error(t, err)
+}
+
+func TestPeekEmpty(t *testing.T) {
+	tests := []struct {
+		input    string
+		offset   uint
+		expected bool
+	}{
+		{"e0", 0, true},                // {}
+		{"0004", 0, true},              // []
+		{"e142656e43466f6f", 0, false}, // {"en": "Foo"}
+		{"010401", 0, false},           // [1]
+		{"40", 0, false},               // ""
+		{"e02000", 1, true},            // pointer to {}
+	}
+
+	for _, test := range tests {
+		inputBytes, err := hex.DecodeString(test.input)
+		require.NoError(t, err)
+		d := decoder{buffer: inputBytes}
+
+		actual, err := d.peekEmpty(test.offset)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, actual, test.input)
+	}
+}
+
 // No pow or bit shifting for big int, apparently :-(
 // This is _not_ meant to be a comprehensive power function.
 func powBigInt(bi *big.Int, pow uint) *big.Int {
@@ -243,3 +338,377 @@ func TestPointers(t *testing.T) {
 		}
 	}
 }
+
+type connType int
+
+const (
+	connTypeUnknown connType = iota
+	connTypeCable
+	connTypeCellular
+)
+
+func (c *connType) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "cable":
+		*c = connTypeCable
+	case "cellular":
+		*c = connTypeCellular
+	default:
+		*c = connTypeUnknown
+	}
+	return nil
+}
+
+func TestDecodingToTextUnmarshalerSlice(t *testing.T) {
+	// ["cable", "cellular", "x"]
+	inputBytes, err := hex.DecodeString("0304456361626c654863656c6c756c61724178")
+	require.NoError(t, err)
+	d := decoder{buffer: inputBytes}
+
+	var result []connType
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+
+	require.Equal(t, []connType{connTypeCable, connTypeCellular, connTypeUnknown}, result)
+}
+
+func (c connType) String() string {
+	return [...]string{"unknown", "cable", "cellular"}[c]
+}
+
+func TestDecodingToTextUnmarshalerInInterface(t *testing.T) {
+	// {"a": "cable", "b": "cellular", "c": "cable"}
+	inputBytes, err := hex.DecodeString(
+		"e3" + "4161" + "456361626c65" + "4162" + "4863656c6c756c6172" + "4163" + "456361626c65",
+	)
+	require.NoError(t, err)
+	d := decoder{buffer: inputBytes}
+
+	var cellular connType = connTypeCellular
+	var result struct {
+		A fmt.Stringer `maxminddb:"a"`
+		B fmt.Stringer `maxminddb:"b"`
+		C fmt.Stringer `maxminddb:"c"`
+	}
+	result.A = connTypeUnknown
+	// A pointer is followed as before.
+	result.B = &cellular
+	// C is nil, so there is no type to decode into.
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.ErrorAs(t, err, &UnmarshalTypeError{})
+
+	result.C = connTypeUnknown
+	cellular = connTypeUnknown
+	_, err = d.decode(0, reflect.ValueOf(&result), 0)
+	require.NoError(t, err)
+	assert.Equal(t, connTypeCable, result.A)
+	assert.Same(t, &cellular, result.B)
+	assert.Equal(t, connTypeCellular, cellular)
+	assert.Equal(t, connTypeCable, result.C)
+}
+
+// testMessage stands in for a serialized message, such as a protocol buffer,
+// stored as bytes. It is a 4-byte ID followed by a name.
+type testMessage struct {
+	Name string
+	ID   uint32
+}
+
+func (m *testMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return errors.New("message too short")
+	}
+	m.ID = binary.BigEndian.Uint32(data)
+	m.Name = string(data[4:])
+	return nil
+}
+
+func TestDecodingToBinaryUnmarshaler(t *testing.T) {
+	// {"msg": bytes(0x0000002a "test")}
+	inputBytes, err := hex.DecodeString("e1436d7367880000002a74657374")
+	require.NoError(t, err)
+	d := decoder{buffer: inputBytes}
+
+	var record struct {
+		Message testMessage `maxminddb:"msg"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&record), 0)
+	require.NoError(t, err)
+	assert.Equal(t, testMessage{ID: 42, Name: "test"}, record.Message)
+
+	var ptrRecord struct {
+		Message *testMessage `maxminddb:"msg"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&ptrRecord), 0)
+	require.NoError(t, err)
+	assert.Equal(t, &testMessage{ID: 42, Name: "test"}, ptrRecord.Message)
+
+	var messages map[string]testMessage
+	_, err = d.decode(0, reflect.ValueOf(&messages), 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]testMessage{"msg": {ID: 42, Name: "test"}}, messages)
+
+	// {"msg": bytes(0x00)}
+	inputBytes, err = hex.DecodeString("e1436d73678100")
+	require.NoError(t, err)
+	d = decoder{buffer: inputBytes}
+	_, err = d.decode(0, reflect.ValueOf(&record), 0)
+	require.ErrorContains(t, err, "message too short")
+}
+
+func TestDecodingWithoutPointerFollowing(t *testing.T) {
+	// "foo" at offset 0 followed by {"a": <pointer to 0>, "b": "bar"} at
+	// offset 4.
+	inputBytes, err := hex.DecodeString("43666f6f" + "e241612000416243626172")
+	require.NoError(t, err)
+
+	d := decoder{buffer: inputBytes}
+	var followed map[string]any
+	_, err = d.decode(4, reflect.ValueOf(&followed), 0)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": "foo", "b": "bar"}, followed)
+
+	d = decoder{
+		buffer: inputBytes,
+		opts:   decoderOptions{disablePointerFollowing: true},
+	}
+	var unfollowed map[string]any
+	_, err = d.decode(4, reflect.ValueOf(&unfollowed), 0)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": uintptr(0), "b": "bar"}, unfollowed)
+
+	var typed struct {
+		A uintptr `maxminddb:"a"`
+		B string  `maxminddb:"b"`
+	}
+	_, err = d.decode(4, reflect.ValueOf(&typed), 0)
+	require.NoError(t, err)
+	require.Equal(t, uintptr(0), typed.A)
+	require.Equal(t, "bar", typed.B)
+
+	var wrongType map[string]string
+	_, err = d.decode(4, reflect.ValueOf(&wrongType), 0)
+	require.Error(t, err)
+}
+
+func TestDecodingBytesMapCopiesValues(t *testing.T) {
+	// {"a": []byte{0x01, 0x02}, "b": []byte{0x03}}
+	inputBytes, err := hex.DecodeString("e2416182010241628103")
+	require.NoError(t, err)
+
+	d := decoder{buffer: inputBytes}
+	var bytesMap map[string][]byte
+	_, err = d.decode(0, reflect.ValueOf(&bytesMap), 0)
+	require.NoError(t, err)
+
+	var anyMap map[string]any
+	_, err = d.decode(0, reflect.ValueOf(&anyMap), 0)
+	require.NoError(t, err)
+
+	// The decoded values must not alias the database buffer, which may be
+	// unmapped when the Reader is closed.
+	for i := range inputBytes {
+		inputBytes[i] = 0xff
+	}
+
+	expected := map[string][]byte{"a": {0x01, 0x02}, "b": {0x03}}
+	require.Equal(t, expected, bytesMap)
+	require.Equal(t, map[string]any{"a": []byte{0x01, 0x02}, "b": []byte{0x03}}, anyMap)
+}
+
+func TestDecodingArrayToPositionalStruct(t *testing.T) {
+	// ["US", uint32(123), 1.5]
+	inputBytes, err := hex.DecodeString("0304425553c17b683ff8000000000000")
+	require.NoError(t, err)
+	d := decoder{buffer: inputBytes}
+
+	type tuple struct {
+		Country string  `maxminddb:"[0]"`
+		ID      uint    `maxminddb:"[1]"`
+		Score   float64 `maxminddb:"[2]"`
+	}
+	var full tuple
+	_, err = d.decode(0, reflect.ValueOf(&full), 0)
+	require.NoError(t, err)
+	require.Equal(t, tuple{Country: "US", ID: 123, Score: 1.5}, full)
+
+	var partial struct {
+		Country string  `maxminddb:"[0]"`
+		Score   float64 `maxminddb:"[2]"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&partial), 0)
+	require.NoError(t, err)
+	require.Equal(t, "US", partial.Country)
+	require.InEpsilon(t, 1.5, partial.Score, 1e-10)
+
+	var invalid struct {
+		Country string `maxminddb:"[x]"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&invalid), 0)
+	require.ErrorContains(t, err, `invalid position "[x]"`)
+
+	var named struct {
+		Country string `maxminddb:"country"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&named), 0)
+	require.ErrorAs(t, err, &UnmarshalTypeError{})
+
+	// {"geo": ["US", uint32(123), 1.5]}
+	inputBytes, err = hex.DecodeString("e14367656f0304425553c17b683ff8000000000000")
+	require.NoError(t, err)
+	d = decoder{buffer: inputBytes}
+
+	var record struct {
+		Geo *tuple `maxminddb:"geo"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&record), 0)
+	require.NoError(t, err)
+	require.Equal(t, &tuple{Country: "US", ID: 123, Score: 1.5}, record.Geo)
+}
+
+func TestDecodingWithTrimStrings(t *testing.T) {
+	// {" key ": "  value\t"}
+	inputBytes, err := hex.DecodeString("e145206b65792048202076616c756509")
+	require.NoError(t, err)
+
+	d := decoder{buffer: inputBytes}
+	var untrimmed map[string]string
+	_, err = d.decode(0, reflect.ValueOf(&untrimmed), 0)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{" key ": "  value\t"}, untrimmed)
+
+	d = decoder{
+		buffer: inputBytes,
+		opts:   decoderOptions{trimStrings: true},
+	}
+	var trimmed map[string]any
+	_, err = d.decode(0, reflect.ValueOf(&trimmed), 0)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{" key ": "value"}, trimmed)
+}
+
+func TestDecodingStringTag(t *testing.T) {
+	// {"asn": "15169", "lat": "51.5", "n": uint16(5)}
+	inputBytes, err := hex.DecodeString("e34361736e453135313639436c61744435312e35416ea105")
+	require.NoError(t, err)
+	d := decoder{buffer: inputBytes}
+
+	var record struct {
+		ASN      uint32  `maxminddb:"asn,string"`
+		Latitude float64 `maxminddb:"lat,string"`
+		N        *uint16 `maxminddb:"n,string"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&record), 0)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(15169), record.ASN)
+	assert.InEpsilon(t, 51.5, record.Latitude, 1e-9)
+	require.NotNil(t, record.N)
+	assert.Equal(t, uint16(5), *record.N)
+
+	var other struct {
+		ASN      string   `maxminddb:"asn"`
+		Latitude *float32 `maxminddb:"lat,string"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&other), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "15169", other.ASN)
+	require.NotNil(t, other.Latitude)
+	assert.InEpsilon(t, float32(51.5), *other.Latitude, 1e-6)
+
+	var overflow struct {
+		ASN uint8 `maxminddb:"asn,string"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&overflow), 0)
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+	require.ErrorContains(t, err, "cannot unmarshal 15169 (string) into type uint8")
+
+	// {"asn": "1x"}
+	inputBytes, err = hex.DecodeString("e14361736e423178")
+	require.NoError(t, err)
+	d = decoder{buffer: inputBytes}
+	var invalid struct {
+		ASN uint32 `maxminddb:"asn,string"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&invalid), 0)
+	require.ErrorAs(t, err, &typeErr)
+
+	var notNumeric struct {
+		ASN string `maxminddb:"asn,string"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&notNumeric), 0)
+	require.ErrorContains(t, err, "string requires an integer or float")
+}
+
+func TestDecodingMapWithHugeSize(t *testing.T) {
+	// A map claiming 65821 + 0xffffff entries followed by a single entry.
+	inputBytes, err := hex.DecodeString("ffffffff" + "4161" + "a101")
+	require.NoError(t, err)
+	d := decoder{buffer: inputBytes}
+
+	assert.Equal(t, 2, d.mapCapacity(16843036, 4))
+	assert.Equal(t, 0, d.mapCapacity(16843036, uint(len(inputBytes))))
+
+	for name, result := range map[string]any{
+		"any": new(any),
+		"map": new(map[string]uint16),
+		"struct": new(struct {
+			A uint16 `maxminddb:"a"`
+		}),
+		"raw map": new(struct {
+			Raw map[string][]byte `maxminddb:",raw"`
+		}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := d.decode(0, reflect.ValueOf(result), 0)
+			require.ErrorContains(t, err, "unexpected end of database")
+		})
+	}
+
+	_, err = d.decodeMapKeys(0)
+	require.ErrorContains(t, err, "unexpected end of database")
+}
+
+func TestDecodingWithMaxDecodeDepth(t *testing.T) {
+	// {"a": [[{"a": uint16(1)}]]}
+	inputBytes, err := hex.DecodeString("e1416101040104e14161a101")
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		maxDepth int
+		err      string
+	}{
+		{maxDepth: 0},
+		{maxDepth: 4},
+		{maxDepth: 3, err: "exceeded maximum decode depth of 3"},
+		{maxDepth: 1, err: "exceeded maximum decode depth of 1"},
+	} {
+		t.Run(fmt.Sprintf("max depth %d", test.maxDepth), func(t *testing.T) {
+			d := decoder{
+				buffer: inputBytes,
+				opts:   decoderOptions{maxDecodeDepth: test.maxDepth},
+			}
+			var result any
+			_, err := d.decode(0, reflect.ValueOf(&result), 0)
+			if test.err != "" {
+				require.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(
+				t,
+				map[string]any{"a": []any{[]any{map[string]any{"a": uint64(1)}}}},
+				result,
+			)
+		})
+	}
+
+	// Decoding into a struct does not count towards the limit.
+	d := decoder{buffer: inputBytes, opts: decoderOptions{maxDecodeDepth: 1}}
+	var record struct {
+		A [][]map[string]uint16 `maxminddb:"a"`
+	}
+	_, err = d.decode(0, reflect.ValueOf(&record), 0)
+	require.NoError(t, err)
+	require.Equal(t, uint16(1), record.A[0][0]["a"])
+}
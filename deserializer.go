@@ -14,6 +14,12 @@ import "math/big"
 // It is not currently covered by any Semantic Versioning guarantees.
 // Use at your own risk.
 type deserializer interface {
+	// ShouldSkip is called with the offset of each value, including the
+	// value a pointer points to, before it is decoded. If it returns true,
+	// no events are emitted for the value or anything nested within it.
+	// As shared data is stored once and referenced through pointers, this
+	// may be used to avoid processing the same data more than once. Map
+	// keys are not passed to ShouldSkip.
 	ShouldSkip(offset uintptr) (bool, error)
 	StartSlice(size uint) error
 	StartMap(size uint) error
@@ -1,6 +1,8 @@
 package maxminddb
 
 import (
+	"encoding/hex"
+	"fmt"
 	"math/big"
 	"net/netip"
 	"testing"
@@ -19,6 +21,42 @@ func TestDecodingToDeserializer(t *testing.T) {
 	checkDecodingToInterface(t, dser.rv)
 }
 
+// dedupDeserializer only emits events for the first occurrence of each
+// offset. Later occurrences are replaced with a reference to the offset.
+type dedupDeserializer struct {
+	testDeserializer
+	seen map[uintptr]bool
+}
+
+func (d *dedupDeserializer) ShouldSkip(offset uintptr) (bool, error) {
+	if d.seen[offset] {
+		return true, d.add(fmt.Sprintf("ref:%d", offset))
+	}
+	d.seen[offset] = true
+	return false, nil
+}
+
+func TestDeserializerShouldSkip(t *testing.T) {
+	// {"x": "y"} at offset 0 followed by {"a": <pointer to 0>, "b": <pointer
+	// to 0>} at offset 5.
+	inputBytes, err := hex.DecodeString("e141784179" + "e24161200041622000")
+	require.NoError(t, err)
+	d := decoder{buffer: inputBytes}
+
+	dser := &dedupDeserializer{seen: map[uintptr]bool{}}
+	_, err = d.decodeToDeserializer(5, dser, 0, false)
+	require.NoError(t, err)
+
+	require.Equal(
+		t,
+		map[string]any{
+			"a": map[string]any{"x": "y"},
+			"b": "ref:0",
+		},
+		dser.rv,
+	)
+}
+
 type stackValue struct {
 	value  any
 	curNum int
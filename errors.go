@@ -1,10 +1,16 @@
 package maxminddb
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 )
 
+// ErrEmptyRecord is returned by Result.Decode and Result.DecodePath when the
+// record is an empty map or array and the Reader was opened with
+// WithReportEmpty.
+var ErrEmptyRecord = errors.New("maxminddb: record is empty")
+
 // InvalidDatabaseError is returned when the database contains invalid data
 // and cannot be parsed.
 type InvalidDatabaseError struct {
@@ -23,6 +29,23 @@ func (e InvalidDatabaseError) Error() string {
 	return e.message
 }
 
+// valueError records the offset and type of the value for which the
+// decoder returned an error. It is only returned when the verifier sets
+// decoderOptions.reportValueErrors.
+type valueError struct {
+	err     error
+	offset  uint
+	typeNum dataType
+}
+
+func (e *valueError) Error() string {
+	return e.err.Error()
+}
+
+func (e *valueError) Unwrap() error {
+	return e.err
+}
+
 // UnmarshalTypeError is returned when the value in the database cannot be
 // assigned to the specified data type.
 type UnmarshalTypeError struct {
@@ -44,3 +67,59 @@ func newUnmarshalTypeError(value any, rType reflect.Type) UnmarshalTypeError {
 func (e UnmarshalTypeError) Error() string {
 	return fmt.Sprintf("maxminddb: cannot unmarshal %s into type %s", e.Value, e.Type)
 }
+
+// VerifySection identifies the part of the database in which Reader.Verify
+// found a problem.
+type VerifySection int
+
+const (
+	// VerifyMetadata is the metadata section.
+	VerifyMetadata VerifySection = iota + 1
+	// VerifySearchTree is the search tree.
+	VerifySearchTree
+	// VerifyDataSectionSeparator is the 16 zero bytes between the search
+	// tree and the data section.
+	VerifyDataSectionSeparator
+	// VerifyDataSection is the data section.
+	VerifyDataSection
+)
+
+func (s VerifySection) String() string {
+	switch s {
+	case VerifyMetadata:
+		return "metadata"
+	case VerifySearchTree:
+		return "search tree"
+	case VerifyDataSectionSeparator:
+		return "data section separator"
+	case VerifyDataSection:
+		return "data section"
+	default:
+		return fmt.Sprintf("VerifySection(%d)", int(s))
+	}
+}
+
+// VerifyError is returned by Reader.Verify and Reader.VerifyPointers. It
+// describes where the problem was found. The wrapped error, usually an
+// InvalidDatabaseError, describes the problem itself.
+type VerifyError struct {
+	Err error
+	// Type is the type of the value at Offset, as named in the MaxMind DB
+	// spec, e.g., "map" or "pointer". It is empty if the type is not known.
+	Type string
+	// Section is the part of the database that is invalid.
+	Section VerifySection
+	// Offset is the offset of the invalid value in the data section, as
+	// used by Reader.LookupOffset, or of the invalid byte of the data
+	// section separator from the start of the file. It is -1 if there is
+	// no specific offset, e.g., for search tree and metadata problems.
+	Offset int
+}
+
+func (e *VerifyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,77 @@
+package maxminddb
+
+// minFieldIndexFields is the number of named fields at or above which a
+// struct's fields are looked up using a fieldIndex rather than a map.
+const minFieldIndexFields = 16
+
+// maxFieldIndexSeeds is the number of seeds tried for each table size when
+// building a fieldIndex.
+const maxFieldIndexSeeds = 256
+
+// fieldIndex is a perfect hash table of the named fields of a struct. Keys
+// are hashed using only their length and three of their bytes, which is
+// cheaper than the hash used by Go maps, and the table is built with a seed
+// for which no two field names collide. A key that hashes to a slot must
+// still be compared with the field name in that slot.
+type fieldIndex struct {
+	entries []fieldIndexEntry
+	seed    uint32
+	shift   uint32
+}
+
+type fieldIndexEntry struct {
+	name string
+	info fieldInfo
+	used bool
+}
+
+// newFieldIndex returns a fieldIndex for fields or nil if no perfect hash
+// was found, e.g., if two field names have the same length and the same
+// bytes at the positions that are hashed.
+func newFieldIndex(fields map[string]fieldInfo) *fieldIndex {
+	bits := uint32(1)
+	for 1<<bits < 2*len(fields) {
+		bits++
+	}
+	// Larger tables are tried if no seed works, at the cost of memory.
+	for maxBits := bits + 2; bits <= maxBits; bits++ {
+		entries := make([]fieldIndexEntry, 1<<bits)
+		shift := 32 - bits
+		seed := uint32(0x9e3779b9)
+	seeds:
+		for range maxFieldIndexSeeds {
+			// Multiplicative hashing requires an odd multiplier.
+			seed = seed*0x2c1b3c6d + 0x297a2d39 | 1
+			clear(entries)
+			for name, info := range fields {
+				e := &entries[fieldHash(name, seed, shift)]
+				if e.used {
+					continue seeds
+				}
+				*e = fieldIndexEntry{name: name, info: info, used: true}
+			}
+			return &fieldIndex{entries: entries, seed: seed, shift: shift}
+		}
+	}
+	return nil
+}
+
+// lookup returns the field named key.
+func (idx *fieldIndex) lookup(key []byte) (fieldInfo, bool) {
+	e := &idx.entries[fieldHash(key, idx.seed, idx.shift)]
+	// The string() does not create a copy due to this compiler
+	// optimization: https://github.com/golang/go/issues/3512
+	if !e.used || e.name != string(key) {
+		return fieldInfo{}, false
+	}
+	return e.info, true
+}
+
+func fieldHash[T string | []byte](key T, seed, shift uint32) uint32 {
+	n := len(key)
+	x := uint32(n)
+	if n > 0 {
+		x |= uint32(key[0])<<8 | uint32(key[n/2])<<16 | uint32(key[n-1])<<24
+	}
+	return (x * seed) >> shift
+}
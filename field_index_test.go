@@ -0,0 +1,153 @@
+package maxminddb
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// enterpriseTraits has the traits of a GeoIP2 Enterprise record, which has
+// enough fields to be looked up using a fieldIndex.
+type enterpriseTraits struct {
+	AutonomousSystemNumber       uint32  `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string  `maxminddb:"autonomous_system_organization"`
+	ConnectionType               string  `maxminddb:"connection_type"`
+	Domain                       string  `maxminddb:"domain"`
+	IsAnonymous                  bool    `maxminddb:"is_anonymous"`
+	IsAnonymousProxy             bool    `maxminddb:"is_anonymous_proxy"`
+	IsAnonymousVPN               bool    `maxminddb:"is_anonymous_vpn"`
+	IsAnycast                    bool    `maxminddb:"is_anycast"`
+	IsHostingProvider            bool    `maxminddb:"is_hosting_provider"`
+	IsLegitimateProxy            bool    `maxminddb:"is_legitimate_proxy"`
+	IsPublicProxy                bool    `maxminddb:"is_public_proxy"`
+	IsResidentialProxy           bool    `maxminddb:"is_residential_proxy"`
+	IsSatelliteProvider          bool    `maxminddb:"is_satellite_provider"`
+	IsTorExitNode                bool    `maxminddb:"is_tor_exit_node"`
+	ISP                          string  `maxminddb:"isp"`
+	MobileCountryCode            string  `maxminddb:"mobile_country_code"`
+	MobileNetworkCode            string  `maxminddb:"mobile_network_code"`
+	Organization                 string  `maxminddb:"organization"`
+	StaticIPScore                float64 `maxminddb:"static_ip_score"`
+	UserCount                    uint32  `maxminddb:"user_count"`
+	UserType                     string  `maxminddb:"user_type"`
+}
+
+// appendTestMap appends a map with the fields of v, which must be a struct,
+// using the field names from their tags, followed by an unknown key.
+func appendTestMap(dst []byte, v any) []byte {
+	rv := reflect.ValueOf(v)
+	dst = append(dst, 0xe0|byte(rv.NumField()+1))
+	appendString := func(s string) {
+		if len(s) < 29 {
+			dst = append(dst, 0x40|byte(len(s)))
+		} else {
+			dst = append(dst, 0x40|29, byte(len(s)-29))
+		}
+		dst = append(dst, s...)
+	}
+	for i := range rv.NumField() {
+		appendString(rv.Type().Field(i).Tag.Get("maxminddb"))
+		switch f := rv.Field(i).Interface().(type) {
+		case bool:
+			if f {
+				dst = append(dst, 0x01, 0x07)
+			} else {
+				dst = append(dst, 0x00, 0x07)
+			}
+		case string:
+			appendString(f)
+		case uint32:
+			dst = append(dst, 0xc4)
+			dst = binary.BigEndian.AppendUint32(dst, f)
+		case float64:
+			dst = append(dst, 0x68)
+			dst = binary.BigEndian.AppendUint64(dst, math.Float64bits(f))
+		}
+	}
+	appendString("unknown")
+	appendString("ignored")
+	return dst
+}
+
+var testEnterpriseTraits = enterpriseTraits{
+	AutonomousSystemNumber:       1239,
+	AutonomousSystemOrganization: "Sprint",
+	ConnectionType:               "Cable/DSL",
+	Domain:                       "example.com",
+	IsAnonymousVPN:               true,
+	IsHostingProvider:            true,
+	IsTorExitNode:                true,
+	ISP:                          "Sprint",
+	MobileCountryCode:            "310",
+	MobileNetworkCode:            "004",
+	Organization:                 "Example",
+	StaticIPScore:                1.5,
+	UserCount:                    2,
+	UserType:                     "residential",
+}
+
+func TestFieldIndex(t *testing.T) {
+	fields, err := cachedFields(reflect.ValueOf(enterpriseTraits{}), false)
+	require.NoError(t, err)
+	require.NotNil(t, fields.namedIndex)
+
+	for name, info := range fields.namedFields {
+		actual, ok := fields.namedIndex.lookup([]byte(name))
+		require.True(t, ok, name)
+		assert.Equal(t, info, actual, name)
+	}
+	for _, key := range []string{"", "i", "isp_", "is_anonymous_vpx", "IS_ANONYMOUS", "user"} {
+		_, ok := fields.namedIndex.lookup([]byte(key))
+		assert.False(t, ok, key)
+	}
+
+	// These names only differ in bytes that are not hashed.
+	assert.Nil(t, newFieldIndex(map[string]fieldInfo{"abxd": {}, "aqxd": {}}))
+
+	small, err := cachedFields(reflect.ValueOf(fullCity{}), false)
+	require.NoError(t, err)
+	assert.Nil(t, small.namedIndex)
+}
+
+func TestDecodingWithFieldIndex(t *testing.T) {
+	d := decoder{buffer: appendTestMap(nil, testEnterpriseTraits)}
+
+	var traits enterpriseTraits
+	_, err := d.decode(0, reflect.ValueOf(&traits), 0)
+	require.NoError(t, err)
+	assert.Equal(t, testEnterpriseTraits, traits)
+}
+
+func BenchmarkDecodeEnterpriseTraits(b *testing.B) {
+	d := decoder{buffer: appendTestMap(nil, testEnterpriseTraits)}
+	fields, err := cachedFields(reflect.ValueOf(enterpriseTraits{}), false)
+	require.NoError(b, err)
+	namedIndex := fields.namedIndex
+
+	for _, useIndex := range []bool{false, true} {
+		name := "map"
+		if useIndex {
+			name = "index"
+		}
+		b.Run(name, func(b *testing.B) {
+			// fields is shared with other decodes of enterpriseTraits, so
+			// it is restored once the benchmark is done.
+			defer func() { fields.namedIndex = namedIndex }()
+			if !useIndex {
+				fields.namedIndex = nil
+			}
+			var traits enterpriseTraits
+			result := reflect.ValueOf(&traits)
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := d.decode(0, result, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
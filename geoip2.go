@@ -0,0 +1,158 @@
+package maxminddb
+
+import "net/netip"
+
+// This file contains convenience helpers for the common fields in the
+// GeoIP2 and GeoLite2 databases. For anything more involved, decode the
+// record into a struct using Result.Decode.
+
+type geoIP2Location struct {
+	TimeZone  string  `maxminddb:"time_zone"`
+	Latitude  float64 `maxminddb:"latitude"`
+	Longitude float64 `maxminddb:"longitude"`
+}
+
+// LookupLocation returns the latitude, longitude, and time zone from the
+// location map of the record for ip. This is intended for GeoIP2 and
+// GeoLite2 City databases or databases with a similar structure.
+//
+// found will be false if there is no record for ip. Fields that are missing
+// from a record that does exist are returned as their zero values.
+func (r *Reader) LookupLocation(ip netip.Addr) (lat, lon float64, tz string, found bool, err error) {
+	result := r.Lookup(ip)
+	if !result.Found() {
+		return 0, 0, "", false, result.Err()
+	}
+
+	var location geoIP2Location
+	if err := result.DecodePath(&location, "location"); err != nil {
+		return 0, 0, "", true, err
+	}
+	return location.Latitude, location.Longitude, location.TimeZone, true, nil
+}
+
+// LookupTimeZone returns the location/time_zone value from the record for
+// ip. found will be false if there is no record for ip.
+func (r *Reader) LookupTimeZone(ip netip.Addr) (tz string, found bool, err error) {
+	result := r.Lookup(ip)
+	if !result.Found() {
+		return "", false, result.Err()
+	}
+
+	err = result.DecodePath(&tz, "location", "time_zone")
+	return tz, true, err
+}
+
+// AnonFlags is a set of the boolean flags from a GeoIP2 Anonymous IP record,
+// as returned by Reader.LookupAnonymousFlags.
+type AnonFlags uint8
+
+const (
+	anonFlagAnonymous AnonFlags = 1 << iota
+	anonFlagAnonymousVPN
+	anonFlagHostingProvider
+	anonFlagPublicProxy
+	anonFlagResidentialProxy
+	anonFlagTorExitNode
+)
+
+// IsAnonymous reports whether the is_anonymous flag is set.
+func (f AnonFlags) IsAnonymous() bool { return f&anonFlagAnonymous != 0 }
+
+// IsAnonymousVPN reports whether the is_anonymous_vpn flag is set.
+func (f AnonFlags) IsAnonymousVPN() bool { return f&anonFlagAnonymousVPN != 0 }
+
+// IsHostingProvider reports whether the is_hosting_provider flag is set.
+func (f AnonFlags) IsHostingProvider() bool { return f&anonFlagHostingProvider != 0 }
+
+// IsPublicProxy reports whether the is_public_proxy flag is set.
+func (f AnonFlags) IsPublicProxy() bool { return f&anonFlagPublicProxy != 0 }
+
+// IsResidentialProxy reports whether the is_residential_proxy flag is set.
+func (f AnonFlags) IsResidentialProxy() bool { return f&anonFlagResidentialProxy != 0 }
+
+// IsTorExitNode reports whether the is_tor_exit_node flag is set.
+func (f AnonFlags) IsTorExitNode() bool { return f&anonFlagTorExitNode != 0 }
+
+type geoIP2AnonymousIP struct {
+	IsAnonymous        bool `maxminddb:"is_anonymous"`
+	IsAnonymousVPN     bool `maxminddb:"is_anonymous_vpn"`
+	IsHostingProvider  bool `maxminddb:"is_hosting_provider"`
+	IsPublicProxy      bool `maxminddb:"is_public_proxy"`
+	IsResidentialProxy bool `maxminddb:"is_residential_proxy"`
+	IsTorExitNode      bool `maxminddb:"is_tor_exit_node"`
+}
+
+// LookupAnonymousFlags returns the boolean flags from the record for ip.
+// This is intended for GeoIP2 Anonymous IP databases. Flags that are missing
+// from the record are not set.
+//
+// found will be false if there is no record for ip.
+func (r *Reader) LookupAnonymousFlags(ip netip.Addr) (flags AnonFlags, found bool, err error) {
+	result := r.Lookup(ip)
+	if !result.Found() {
+		return 0, false, result.Err()
+	}
+
+	var record geoIP2AnonymousIP
+	if err := result.Decode(&record); err != nil {
+		return 0, true, err
+	}
+	for _, flag := range []struct {
+		set  bool
+		flag AnonFlags
+	}{
+		{record.IsAnonymous, anonFlagAnonymous},
+		{record.IsAnonymousVPN, anonFlagAnonymousVPN},
+		{record.IsHostingProvider, anonFlagHostingProvider},
+		{record.IsPublicProxy, anonFlagPublicProxy},
+		{record.IsResidentialProxy, anonFlagResidentialProxy},
+		{record.IsTorExitNode, anonFlagTorExitNode},
+	} {
+		if flag.set {
+			flags |= flag.flag
+		}
+	}
+	return flags, true, nil
+}
+
+// RepresentedCountryType is the type of the country represented by the users
+// of an IP address, as stored in the represented_country/type value of GeoIP2
+// records, e.g., for a military base. It implements encoding.TextUnmarshaler,
+// so it may be used as the type of a struct field that the value is decoded
+// into.
+type RepresentedCountryType uint8
+
+const (
+	// RepresentedCountryTypeUnknown is used for values that are not
+	// otherwise known.
+	RepresentedCountryTypeUnknown RepresentedCountryType = iota
+	// RepresentedCountryTypeMilitary is used for military bases.
+	RepresentedCountryTypeMilitary
+)
+
+var representedCountryTypeNames = map[RepresentedCountryType]string{
+	RepresentedCountryTypeUnknown:  "unknown",
+	RepresentedCountryTypeMilitary: "military",
+}
+
+// String returns the value of the type in the database, or "unknown".
+func (t RepresentedCountryType) String() string {
+	if name, ok := representedCountryTypeNames[t]; ok {
+		return name
+	}
+	return representedCountryTypeNames[RepresentedCountryTypeUnknown]
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. Values that are not
+// known are decoded as RepresentedCountryTypeUnknown rather than returning
+// an error, as new types may be added to the databases.
+func (t *RepresentedCountryType) UnmarshalText(text []byte) error {
+	*t = RepresentedCountryTypeUnknown
+	for value, name := range representedCountryTypeNames {
+		if name == string(text) {
+			*t = value
+		}
+	}
+	return nil
+}
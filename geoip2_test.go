@@ -0,0 +1,85 @@
+package maxminddb
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupLocation(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	lat, lon, tz, found, err := reader.LookupLocation(netip.MustParseAddr("81.2.69.142"))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.InEpsilon(t, 51.5142, lat, 1e-10)
+	assert.InEpsilon(t, -0.0931, lon, 1e-10)
+	assert.Equal(t, "Europe/London", tz)
+
+	tz, found, err = reader.LookupTimeZone(netip.MustParseAddr("81.2.69.142"))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "Europe/London", tz)
+
+	_, _, _, found, err = reader.LookupLocation(netip.MustParseAddr("10.0.0.1"))
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestLookupAnonymousFlags(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-Anonymous-IP-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	flags, found, err := reader.LookupAnonymousFlags(netip.MustParseAddr("1.2.0.1"))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.True(t, flags.IsAnonymous())
+	assert.True(t, flags.IsAnonymousVPN())
+	assert.False(t, flags.IsHostingProvider())
+	assert.False(t, flags.IsPublicProxy())
+	assert.False(t, flags.IsResidentialProxy())
+	assert.False(t, flags.IsTorExitNode())
+
+	flags, found, err = reader.LookupAnonymousFlags(netip.MustParseAddr("81.2.69.1"))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.True(t, flags.IsAnonymous())
+	assert.True(t, flags.IsAnonymousVPN())
+	assert.True(t, flags.IsHostingProvider())
+	assert.True(t, flags.IsPublicProxy())
+	assert.True(t, flags.IsResidentialProxy())
+	assert.True(t, flags.IsTorExitNode())
+
+	flags, found, err = reader.LookupAnonymousFlags(netip.MustParseAddr("10.0.0.1"))
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Zero(t, flags)
+}
+
+func TestRepresentedCountryType(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var record struct {
+		RepresentedCountry struct {
+			IsoCode string                 `maxminddb:"iso_code"`
+			Type    RepresentedCountryType `maxminddb:"type"`
+		} `maxminddb:"represented_country"`
+	}
+	require.NoError(t, reader.Lookup(netip.MustParseAddr("202.196.224.1")).Decode(&record))
+	assert.Equal(t, "US", record.RepresentedCountry.IsoCode)
+	assert.Equal(t, RepresentedCountryTypeMilitary, record.RepresentedCountry.Type)
+	assert.Equal(t, "military", record.RepresentedCountry.Type.String())
+
+	var typ RepresentedCountryType
+	require.NoError(t, typ.UnmarshalText([]byte("embassy")))
+	assert.Equal(t, RepresentedCountryTypeUnknown, typ)
+	assert.Equal(t, "unknown", typ.String())
+	assert.Equal(t, "unknown", RepresentedCountryType(100).String())
+}
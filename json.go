@@ -0,0 +1,242 @@
+package maxminddb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// MarshalJSON implements json.Marshaler. The record is converted to JSON
+// directly from the data section without first decoding it into Go values.
+// Maps become JSON objects and arrays become JSON arrays. Bytes are encoded
+// as base64 strings and uint128 values as decimal strings, as they cannot be
+// represented exactly by a JSON number. A Result that was not found is
+// marshaled as null.
+func (r Result) MarshalJSON() ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.offset == notFound {
+		return []byte("null"), nil
+	}
+	return r.appendJSON(nil)
+}
+
+// appendJSON appends the JSON for the record to dst.
+func (r Result) appendJSON(dst []byte) (b []byte, err error) {
+	defer r.recoverPanic(&err)
+	b, _, err = r.decoder.appendJSON(dst, r.offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// jsonBuffers holds the buffers used by LookupJSONInto so that writing a
+// record does not allocate once the buffers have grown to the size of the
+// records. Buffers larger than maxPooledJSONBuffer are not returned to the
+// pool so that an unusually large record does not pin its memory.
+var jsonBuffers = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 1024)
+		return &b
+	},
+}
+
+const maxPooledJSONBuffer = 64 * 1024
+
+// LookupJSONInto looks up ip and writes its record to w as JSON, as with
+// Result.MarshalJSON, returning the network of the record and whether it was
+// found. Nothing is written if the record is not found or if there is an
+// error converting the record. This is intended for writing records directly
+// to a response, e.g., an http.ResponseWriter.
+func (r *Reader) LookupJSONInto(w io.Writer, ip netip.Addr) (netip.Prefix, bool, error) {
+	result := r.Lookup(ip)
+	if err := result.Err(); err != nil {
+		return netip.Prefix{}, false, err
+	}
+	prefix := result.Prefix()
+	if !result.Found() {
+		return prefix, false, nil
+	}
+
+	buf := jsonBuffers.Get().(*[]byte)
+	dst, err := result.appendJSON((*buf)[:0])
+	if err != nil {
+		jsonBuffers.Put(buf)
+		return prefix, true, err
+	}
+	_, err = w.Write(dst)
+	if cap(dst) <= maxPooledJSONBuffer {
+		*buf = dst
+		jsonBuffers.Put(buf)
+	}
+	return prefix, true, err
+}
+
+// appendJSON appends the JSON for the value at offset to dst, returning the
+// offset of the next value.
+func (d *decoder) appendJSON(dst []byte, offset uint, depth int) ([]byte, uint, error) {
+	if depth > maximumDataStructureDepth {
+		return nil, 0, newInvalidDatabaseError(
+			"exceeded maximum data structure depth; database is likely corrupt",
+		)
+	}
+	typeNum, size, offset, err := d.decodeCtrlData(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// For these types, size has a special meaning
+	switch typeNum {
+	case _Bool:
+		return strconv.AppendBool(dst, size != 0), offset, nil
+	case _Pointer:
+		pointer, newOffset, err := d.decodePointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		dst, _, err = d.appendJSON(dst, pointer, depth+1)
+		return dst, newOffset, err
+	case _Map:
+		dst = append(dst, '{')
+		for i := uint(0); i < size; i++ {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			var key []byte
+			key, offset, err = d.decodeKey(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			dst = appendJSONString(dst, key)
+			dst = append(dst, ':')
+			dst, offset, err = d.appendJSON(dst, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return append(dst, '}'), offset, nil
+	case _Slice:
+		dst = append(dst, '[')
+		for i := uint(0); i < size; i++ {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst, offset, err = d.appendJSON(dst, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return append(dst, ']'), offset, nil
+	}
+
+	// For the remaining types, size is the byte size
+	newOffset := offset + size
+	if newOffset > uint(len(d.buffer)) {
+		return nil, 0, newOffsetError()
+	}
+	value := d.buffer[offset:newOffset]
+	switch typeNum {
+	case _Bytes:
+		dst = append(dst, '"')
+		dst = base64.StdEncoding.AppendEncode(dst, value)
+		return append(dst, '"'), newOffset, nil
+	case _String:
+		return appendJSONString(dst, value), newOffset, nil
+	case _Float32:
+		if size != 4 {
+			return nil, 0, newInvalidDatabaseError(
+				"the MaxMind DB file's data section contains bad data (float32 size of %v)",
+				size,
+			)
+		}
+		f, _ := d.decodeFloat32(size, offset)
+		dst, err = appendJSONFloat(dst, float64(f), 32)
+		return dst, newOffset, err
+	case _Float64:
+		if size != 8 {
+			return nil, 0, newInvalidDatabaseError(
+				"the MaxMind DB file's data section contains bad data (float 64 size of %v)",
+				size,
+			)
+		}
+		f, _ := d.decodeFloat64(size, offset)
+		dst, err = appendJSONFloat(dst, f, 64)
+		return dst, newOffset, err
+	case _Int32:
+		if size > 4 {
+			return nil, 0, newInvalidDatabaseError(
+				"the MaxMind DB file's data section contains bad data (int32 size of %v)",
+				size,
+			)
+		}
+		n, _ := d.decodeInt(size, offset)
+		return strconv.AppendInt(dst, int64(n), 10), newOffset, nil
+	case _Uint16, _Uint32, _Uint64:
+		uintType := uint(64)
+		switch typeNum {
+		case _Uint16:
+			uintType = 16
+		case _Uint32:
+			uintType = 32
+		}
+		if size > uintType/8 {
+			return nil, 0, newInvalidDatabaseError(
+				"the MaxMind DB file's data section contains bad data (uint%v size of %v)",
+				uintType,
+				size,
+			)
+		}
+		n, _ := d.decodeUint(size, offset)
+		return strconv.AppendUint(dst, n, 10), newOffset, nil
+	case _Uint128:
+		if size > 16 {
+			return nil, 0, newInvalidDatabaseError(
+				"the MaxMind DB file's data section contains bad data (uint128 size of %v)",
+				size,
+			)
+		}
+		n, _ := d.decodeUint128(size, offset)
+		dst = append(dst, '"')
+		dst = n.Append(dst, 10)
+		return append(dst, '"'), newOffset, nil
+	default:
+		return nil, 0, newInvalidDatabaseError("unknown type: %d", typeNum)
+	}
+}
+
+func appendJSONFloat(dst []byte, f float64, bitSize int) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("cannot represent %v as JSON", f)
+	}
+	return strconv.AppendFloat(dst, f, 'g', -1, bitSize), nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a quoted JSON string. Invalid UTF-8 is
+// replaced with U+FFFD, as with encoding/json.
+func appendJSONString(dst, s []byte) []byte {
+	dst = append(dst, '"')
+	for len(s) > 0 {
+		r, size := utf8.DecodeRune(s)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, "\ufffd"...)
+		case r == '"' || r == '\\':
+			dst = append(dst, '\\', byte(r))
+		case r < 0x20:
+			dst = append(dst, '\\', 'u', '0', '0', hexDigits[r>>4], hexDigits[r&0xf])
+		default:
+			dst = append(dst, s[:size]...)
+		}
+		s = s[size:]
+	}
+	return append(dst, '"')
+}
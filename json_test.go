@@ -0,0 +1,126 @@
+package maxminddb
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultMarshalJSON(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-decoder.mmdb",
+		"MaxMind-DB-test-nested.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			for result := range reader.Networks() {
+				require.NoError(t, result.Err())
+
+				var record any
+				require.NoError(t, result.Decode(&record))
+				expected, err := json.Marshal(uint128sToStrings(record))
+				require.NoError(t, err)
+
+				actual, err := json.Marshal(result)
+				require.NoError(t, err)
+				assert.JSONEq(t, string(expected), string(actual), result.Prefix().String())
+			}
+		})
+	}
+}
+
+func TestResultMarshalJSONValues(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	actual, err := reader.Lookup(netip.MustParseAddr("::1.1.1.0")).MarshalJSON()
+	require.NoError(t, err)
+
+	var record map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(actual, &record))
+	assert.JSONEq(t, `"1329227995784915872903807060280344576"`, string(record["uint128"]))
+	assert.JSONEq(t, `"AAAAKg=="`, string(record["bytes"]))
+	assert.JSONEq(t, `-268435456`, string(record["int32"]))
+	assert.JSONEq(t, `[1,2,3]`, string(record["array"]))
+
+	notFound, err := reader.Lookup(netip.MustParseAddr("ffff::1")).MarshalJSON()
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(notFound))
+}
+
+func TestAppendJSONString(t *testing.T) {
+	for input, expected := range map[string]string{
+		"":              `""`,
+		"abc":           `"abc"`,
+		`a"b\c`:         `"a\"b\\c"`,
+		"tab\there\n":   `"tab\u0009here\u000a"`,
+		"日本":            `"日本"`,
+		"invalid\xffok": `"invalid�ok"`,
+	} {
+		actual := appendJSONString(nil, []byte(input))
+		require.True(t, json.Valid(actual), input)
+		var decoded string
+		require.NoError(t, json.Unmarshal(actual, &decoded))
+		var expectedDecoded string
+		require.NoError(t, json.Unmarshal([]byte(expected), &expectedDecoded))
+		assert.Equal(t, expectedDecoded, decoded, input)
+	}
+}
+
+// uint128sToStrings replaces the *big.Int values in a decoded record with
+// their decimal strings, matching MarshalJSON.
+func uint128sToStrings(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, value := range v {
+			v[k] = uint128sToStrings(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = uint128sToStrings(value)
+		}
+	case *big.Int:
+		return v.String()
+	}
+	return v
+}
+
+func TestLookupJSONInto(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	for _, ip := range []string{"::1.1.1.0", "::0.0.0.0", "::1.1.1.32"} {
+		addr := netip.MustParseAddr(ip)
+		result := reader.Lookup(addr)
+		expected, err := result.MarshalJSON()
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		prefix, found, err := reader.LookupJSONInto(&buf, addr)
+		require.NoError(t, err)
+		assert.True(t, found, ip)
+		assert.Equal(t, result.Prefix(), prefix, ip)
+		assert.Equal(t, string(expected), buf.String(), ip)
+	}
+
+	var buf bytes.Buffer
+	prefix, found, err := reader.LookupJSONInto(&buf, netip.MustParseAddr("ffff::1"))
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.True(t, prefix.IsValid())
+	assert.Zero(t, buf.Len())
+
+	require.NoError(t, reader.Close())
+	_, _, err = reader.LookupJSONInto(&buf, netip.MustParseAddr("::1.1.1.0"))
+	require.Error(t, err)
+}
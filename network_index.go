@@ -0,0 +1,215 @@
+package maxminddb
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+)
+
+var networkIndexMagic = []byte("MMDBNIX1")
+
+// networkIndex is a sorted list of the networks with data in the database,
+// as imported by ImportNetworkIndex.
+type networkIndex struct {
+	ipv4 []indexedNetwork
+	ipv6 []indexedNetwork
+}
+
+type indexedNetwork struct {
+	prefix netip.Prefix
+	offset uint
+}
+
+// ExportNetworkIndex writes the networks in the database that have data,
+// along with the offsets of their records, to w in a compact binary format.
+// The output may be passed to ImportNetworkIndex on a later Reader for the
+// same database to allow LookupIndexed to be used without traversing the
+// search tree. The output includes a hash of the database's metadata and may
+// only be imported for the same database.
+func (r *Reader) ExportNetworkIndex(w io.Writer) error {
+	hash, err := r.metadataHash()
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(networkIndexMagic); err != nil {
+		return err
+	}
+	if _, err := bw.Write(hash[:]); err != nil {
+		return err
+	}
+
+	var buf []byte
+	for result := range r.Networks() {
+		if err := result.Err(); err != nil {
+			return err
+		}
+		prefix := result.Prefix()
+		buf = buf[:0]
+		buf = append(buf, byte(prefix.Bits()))
+		if prefix.Addr().Is4() {
+			buf = append(buf, 4)
+		} else {
+			buf = append(buf, 6)
+		}
+		buf = append(buf, prefix.Addr().AsSlice()...)
+		buf = binary.AppendUvarint(buf, uint64(result.offset))
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ImportNetworkIndex reads an index written by ExportNetworkIndex for use by
+// LookupIndexed. An error is returned if the index was exported from a
+// different database. The index replaces any previously imported index.
+func (r *Reader) ImportNetworkIndex(rd io.Reader) error {
+	hash, err := r.metadataHash()
+	if err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(rd)
+	header := make([]byte, len(networkIndexMagic)+len(hash))
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("reading network index header: %w", err)
+	}
+	if !bytes.Equal(header[:len(networkIndexMagic)], networkIndexMagic) {
+		return errors.New("invalid network index")
+	}
+	if !bytes.Equal(header[len(networkIndexMagic):], hash[:]) {
+		return errors.New("the network index was not exported from this database")
+	}
+
+	index := &networkIndex{}
+	for {
+		bits, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading network index: %w", err)
+		}
+		network, err := readIndexedNetwork(br, int(bits))
+		if err != nil {
+			return fmt.Errorf("reading network index: %w", err)
+		}
+		if network.prefix.Addr().Is4() {
+			index.ipv4 = append(index.ipv4, network)
+		} else {
+			index.ipv6 = append(index.ipv6, network)
+		}
+	}
+
+	compare := func(a, b indexedNetwork) int {
+		return a.prefix.Addr().Compare(b.prefix.Addr())
+	}
+	slices.SortFunc(index.ipv4, compare)
+	slices.SortFunc(index.ipv6, compare)
+
+	r.networkIndex.Store(index)
+	return nil
+}
+
+func readIndexedNetwork(br *bufio.Reader, bits int) (indexedNetwork, error) {
+	version, err := br.ReadByte()
+	if err != nil {
+		return indexedNetwork{}, noEOF(err)
+	}
+	var addrLen int
+	switch version {
+	case 4:
+		addrLen = 4
+	case 6:
+		addrLen = 16
+	default:
+		return indexedNetwork{}, fmt.Errorf("invalid IP version %d", version)
+	}
+	addrBytes := make([]byte, addrLen)
+	if _, err := io.ReadFull(br, addrBytes); err != nil {
+		return indexedNetwork{}, noEOF(err)
+	}
+	addr, _ := netip.AddrFromSlice(addrBytes)
+	prefix := netip.PrefixFrom(addr, bits)
+	if !prefix.IsValid() {
+		return indexedNetwork{}, fmt.Errorf("invalid prefix length %d for %s", bits, addr)
+	}
+	offset, err := binary.ReadUvarint(br)
+	if err != nil {
+		return indexedNetwork{}, noEOF(err)
+	}
+	return indexedNetwork{prefix: prefix, offset: uint(offset)}, nil
+}
+
+// noEOF converts io.EOF into io.ErrUnexpectedEOF for reads that are in the
+// middle of an entry.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// LookupIndexed retrieves the database record for ip using the index
+// imported by ImportNetworkIndex rather than the search tree. Addresses that
+// are not in the index, e.g., those without data or those only reachable
+// through an alias of the IPv4 subtree, are looked up using Lookup. An error
+// Result is returned if no index has been imported.
+func (r *Reader) LookupIndexed(ip netip.Addr) Result {
+	if r.buffer == nil {
+		return Result{err: errors.New("cannot call LookupIndexed on a closed database")}
+	}
+	index := r.networkIndex.Load()
+	if index == nil {
+		return Result{ip: ip, err: errors.New("no network index has been imported")}
+	}
+
+	addr := r.lookupAddr(ip)
+	networks := index.ipv6
+	if addr.Is4() {
+		networks = index.ipv4
+	}
+	i, found := slices.BinarySearchFunc(networks, addr, func(n indexedNetwork, addr netip.Addr) int {
+		return n.prefix.Addr().Compare(addr)
+	})
+	if !found {
+		i--
+	}
+	if i < 0 || !networks[i].prefix.Contains(addr) {
+		return r.Lookup(ip)
+	}
+
+	network := networks[i]
+	prefixLen := network.prefix.Bits()
+	if addr.Is4() {
+		prefixLen += 96
+	}
+	return Result{
+		decoder:             r.decoder,
+		ip:                  ip,
+		offset:              network.offset,
+		prefixLen:           uint8(prefixLen),
+		normalizeIPv4Prefix: r.normalizeIPv4Prefix,
+	}
+}
+
+// metadataHash returns a hash of the metadata section, which identifies the
+// database for ImportNetworkIndex.
+func (r *Reader) metadataHash() ([sha256.Size]byte, error) {
+	if r.buffer == nil {
+		return [sha256.Size]byte{}, errors.New("cannot use a closed database")
+	}
+	_, markerStart, err := findMetadata(r.buffer, 0)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(r.buffer[markerStart:]), nil
+}
@@ -0,0 +1,118 @@
+package maxminddb
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkIndexRoundTrip(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			exporter, err := Open(testFile(database))
+			require.NoError(t, err)
+			defer exporter.Close()
+
+			var buf bytes.Buffer
+			require.NoError(t, exporter.ExportNetworkIndex(&buf))
+
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			assert.Error(t, reader.LookupIndexed(netip.MustParseAddr("1.1.1.1")).Err())
+
+			require.NoError(t, reader.ImportNetworkIndex(&buf))
+
+			for network := range reader.Networks(IncludeNetworksWithoutData) {
+				require.NoError(t, network.Err())
+				for _, ip := range []netip.Addr{
+					network.Prefix().Addr(),
+					lastAddr(network.Prefix()),
+				} {
+					expected := reader.Lookup(ip)
+					actual := reader.LookupIndexed(ip)
+					require.NoError(t, actual.Err())
+					assert.Equal(t, expected.Found(), actual.Found(), ip)
+					assert.Equal(t, expected.Prefix(), actual.Prefix(), ip)
+					assert.Equal(t, expected.Offset(), actual.Offset(), ip)
+				}
+			}
+		})
+	}
+}
+
+func TestLookupIndexedIPv4Mapped(t *testing.T) {
+	for _, mode := range []IPv4MappedMode{IPv4MappedAsIPv6, IPv4MappedAsIPv4} {
+		reader, err := Open(testFile("MaxMind-DB-test-mixed-24.mmdb"), WithIPv4MappedMode(mode))
+		require.NoError(t, err)
+		defer reader.Close()
+
+		var buf bytes.Buffer
+		require.NoError(t, reader.ExportNetworkIndex(&buf))
+		require.NoError(t, reader.ImportNetworkIndex(&buf))
+
+		ip := netip.MustParseAddr("::ffff:1.1.1.1")
+		expected := reader.Lookup(ip)
+		actual := reader.LookupIndexed(ip)
+		require.NoError(t, actual.Err())
+		assert.Equal(t, expected.Found(), actual.Found(), mode)
+		assert.Equal(t, expected.Prefix(), actual.Prefix(), mode)
+		assert.Equal(t, expected.Offset(), actual.Offset(), mode)
+
+		if mode == IPv4MappedAsIPv4 {
+			// The address must be found in the IPv4 index rather than by
+			// falling back to Lookup.
+			index := reader.networkIndex.Load()
+			for i := range index.ipv4 {
+				if index.ipv4[i].prefix.Contains(ip.Unmap()) {
+					index.ipv4[i].offset++
+				}
+			}
+			assert.Equal(t, expected.Offset()+1, reader.LookupIndexed(ip).Offset())
+		}
+	}
+}
+
+func TestNetworkIndexFromOtherDatabase(t *testing.T) {
+	city, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer city.Close()
+
+	var buf bytes.Buffer
+	require.NoError(t, city.ExportNetworkIndex(&buf))
+	exported := buf.Bytes()
+
+	country, err := Open(testFile("GeoIP2-Country-Test.mmdb"))
+	require.NoError(t, err)
+	defer country.Close()
+
+	require.EqualError(
+		t,
+		country.ImportNetworkIndex(bytes.NewReader(exported)),
+		"the network index was not exported from this database",
+	)
+
+	require.Error(t, city.ImportNetworkIndex(bytes.NewReader(exported[:len(exported)-1])))
+	require.EqualError(
+		t,
+		city.ImportNetworkIndex(bytes.NewReader([]byte("not an index at all, not at all, not at all"))),
+		"invalid network index",
+	)
+}
+
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
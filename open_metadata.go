@@ -0,0 +1,40 @@
+package maxminddb
+
+import "os"
+
+// metadataMaxSize is the maximum size of the metadata section, including
+// the 14 byte start marker. The MaxMind DB spec limits the metadata to
+// 128KiB.
+const metadataMaxSize = 128*1024 + 14
+
+// OpenMetadata reads the metadata of the MaxMind DB file at the given path
+// without opening the database. Only the end of the file, where the metadata
+// is stored, is read, and no memory map is created. This is useful when
+// inspecting many databases, e.g., to check their types or build times.
+func OpenMetadata(file string) (Metadata, error) {
+	return openMetadata(file, metadataMaxSize)
+}
+
+// openMetadata reads the metadata from the last maxSize bytes of the file.
+func openMetadata(file string, maxSize int) (Metadata, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Metadata{}, err
+	}
+	//nolint:errcheck // the file is only read, so a close error is unimportant
+	defer f.Close()
+
+	stats, err := f.Stat()
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	tailStart := max(stats.Size()-int64(maxSize), 0)
+	tail := make([]byte, stats.Size()-tailStart)
+	if _, err := f.ReadAt(tail, tailStart); err != nil {
+		return Metadata{}, err
+	}
+
+	metadata, _, err := findMetadata(tail, int(tailStart))
+	return metadata, err
+}
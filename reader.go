@@ -3,10 +3,16 @@ package maxminddb
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"iter"
+	"math/bits"
 	"net/netip"
 	"reflect"
+	"slices"
+	"sync/atomic"
 )
 
 const dataSectionSeparatorSize = 16
@@ -27,6 +33,12 @@ type Reader struct {
 	ipv4StartBitDepth int
 	nodeOffsetMult    uint
 	hasMappedFile     bool
+	// normalizeIPv4Prefix is set by WithIPv4PrefixNormalization.
+	normalizeIPv4Prefix bool
+	// ipv4MappedMode is set by WithIPv4MappedMode.
+	ipv4MappedMode IPv4MappedMode
+	// networkIndex is set by ImportNetworkIndex.
+	networkIndex atomic.Pointer[networkIndex]
 }
 
 // Metadata holds the metadata decoded from the MaxMind DB file. In particular
@@ -45,34 +57,237 @@ type Metadata struct {
 	RecordSize               uint              `maxminddb:"record_size"`
 }
 
-// FromBytes takes a byte slice corresponding to a MaxMind DB file and returns
-// a Reader structure or an error.
-func FromBytes(buffer []byte) (*Reader, error) {
-	metadataStart := bytes.LastIndex(buffer, metadataStartMarker)
+type readerOptions struct {
+	decoder             decoderOptions
+	maxFileSize         int64
+	normalizeIPv4Prefix bool
+	ipv4MappedMode      IPv4MappedMode
+}
+
+// ReaderOption are options for Open and FromBytes.
+type ReaderOption func(*readerOptions)
+
+// WithoutPointerFollowing is a ReaderOption that makes decoding stop at
+// pointers in the data section. Rather than decoding the value that a pointer
+// refers to, the offset of that value is stored. The destination must be a
+// uintptr or an empty interface, in which case a uintptr is stored. The
+// offset may be passed to Reader.LookupOffset.
+//
+// This exposes the internal layout of the data section and is intended for
+// diagnostic tooling only. The layout of a record may change between
+// database builds. To inspect a single record without affecting other
+// decodes, use Result.DecodeWithoutPointerFollowing instead.
+func WithoutPointerFollowing(options *readerOptions) {
+	options.decoder.disablePointerFollowing = true
+}
+
+// WithCollectErrors is a ReaderOption that makes decoding into a struct
+// continue past fields whose values cannot be stored in the field's type.
+// Such fields are left as their zero value and the errors for all of them
+// are returned together, joined with errors.Join, once decoding completes.
+// Each error includes the path of keys to the field. Errors caused by invalid
+// data in the database still stop decoding immediately.
+//
+// This is intended for validation tooling that wants to report every
+// mismatch between a struct and a database in a single pass.
+func WithCollectErrors(options *readerOptions) {
+	options.decoder.collectErrors = true
+}
+
+// WithReportEmpty is a ReaderOption that makes Result.Decode and
+// Result.DecodePath return ErrEmptyRecord when the record is an empty map or
+// array. By default, decoding an empty record succeeds and leaves the value
+// unchanged, which cannot be distinguished from a path that was not found.
+func WithReportEmpty(options *readerOptions) {
+	options.decoder.reportEmpty = true
+}
+
+// WithTrimStrings is a ReaderOption that removes leading and trailing white
+// space from decoded string values. Map keys are not modified. This is
+// intended for third-party databases with stray white space in their values.
+// As it changes the data, it is not enabled by default.
+func WithTrimStrings(options *readerOptions) {
+	options.decoder.trimStrings = true
+}
 
-	if metadataStart == -1 {
-		return nil, newInvalidDatabaseError("error opening database: invalid MaxMind DB file")
+// WithFloatAsString is a ReaderOption that makes float and double values
+// decoded into an empty interface, e.g., the values of a map[string]any, be
+// stored as strings rather than as a float32 or float64. The string is the
+// shortest representation that parses back to the same value. This is
+// intended for pipelines that pass values through to JSON or similar formats
+// and must not reformat them. Decoding into a float field is not affected.
+func WithFloatAsString(options *readerOptions) {
+	options.decoder.floatAsString = true
+}
+
+// WithUntaggedLowercase is a ReaderOption that makes struct fields without a
+// key in their maxminddb tag match the lowercased field name rather than the
+// exact field name. For example, an untagged field named Country matches the
+// key "country". Fields with a key in their tag are not affected. This is
+// intended for databases with lowercase keys that do not need a tag on every
+// field.
+func WithUntaggedLowercase(options *readerOptions) {
+	options.decoder.untaggedLowercase = true
+}
+
+// IPv4MappedMode controls how IPv4-mapped IPv6 addresses, e.g.,
+// ::ffff:1.2.3.4, are looked up. See WithIPv4MappedMode.
+type IPv4MappedMode int
+
+const (
+	// IPv4MappedAsIPv6 looks up IPv4-mapped IPv6 addresses as IPv6
+	// addresses, i.e., in ::ffff:0:0/96 of the search tree. In MaxMind's IPv6
+	// databases this network is an alias of the IPv4 subtree, but other
+	// databases may have different data there or none at all. In an IPv4
+	// database, looking up such an address is an error. This is the default.
+	IPv4MappedAsIPv6 IPv4MappedMode = iota
+	// IPv4MappedAsIPv4 converts IPv4-mapped IPv6 addresses to IPv4 addresses
+	// with netip.Addr.Unmap before looking them up, so ::ffff:1.2.3.4 and
+	// 1.2.3.4 have the same record in every database.
+	IPv4MappedAsIPv4
+)
+
+// WithIPv4MappedMode returns a ReaderOption that sets how IPv4-mapped IPv6
+// addresses are looked up. The default is IPv4MappedAsIPv6. The mode does
+// not change the address in the Result, so Result.Prefix returns an
+// IPv4-mapped network for an IPv4-mapped address in either mode.
+func WithIPv4MappedMode(mode IPv4MappedMode) ReaderOption {
+	return func(options *readerOptions) {
+		options.ipv4MappedMode = mode
 	}
+}
 
-	metadataStart += len(metadataStartMarker)
-	metadataDecoder := decoder{buffer: buffer[metadataStart:]}
+// WithPanicRecovery is a ReaderOption that makes the methods and functions
+// that decode a Result's record, such as Result.Decode, Result.DecodePath,
+// Result.MarshalJSON, DecodeSliceInto, and LookupPath, as well as Networks,
+// NetworksWithin, and NetworksColumnar, recover from panics and return them
+// as an InvalidDatabaseError rather than crashing the program.
+// The decoder checks the bounds of everything it reads, so a panic indicates
+// either a bug in this package or a database corrupted in a way that has not
+// been anticipated. Panics from the body of a range loop over Networks are
+// not recovered.
+//
+// This is intended for services that must not be taken down by a single bad
+// database. It is not enabled by default as it may mask bugs.
+func WithPanicRecovery(options *readerOptions) {
+	options.decoder.recoverPanics = true
+}
 
-	var metadata Metadata
+// WithIPv4PrefixNormalization is a ReaderOption that changes the network
+// reported by Result.Prefix for IPv4 lookups when the record was found above
+// the IPv4 subtree, i.e., at a prefix shorter than ::/96. This happens with
+// IPv6 databases that do not have an IPv4 search tree and instead store the
+// data for IPv4 addresses in a larger IPv6 network such as ::/64. By default,
+// the IPv6 network, e.g., ::/64, is returned. With this option, 0.0.0.0/0 is
+// returned instead, as the record applies to every IPv4 address.
+//
+// The normalized prefix does not correspond to a network in the search tree.
+// As the data is only stored in IPv6 space, Networks and NetworksWithin still
+// return the IPv6 network.
+func WithIPv4PrefixNormalization(options *readerOptions) {
+	options.normalizeIPv4Prefix = true
+}
+
+// WithLogger returns a ReaderOption that reports non-fatal anomalies to fn
+// rather than silently ignoring them. These include unknown options in
+// maxminddb struct tags, which are reported the first time a struct type is
+// decoded, and metadata without a description. Each distinct message is
+// reported once per Reader. fn may be called concurrently from multiple
+// goroutines. By default, nothing is reported.
+func WithLogger(fn func(msg string)) ReaderOption {
+	return func(options *readerOptions) {
+		if fn == nil {
+			options.decoder.logger = nil
+			return
+		}
+		options.decoder.logger = &readerLogger{fn: fn}
+	}
+}
+
+// WithMaxDecodeDepth returns a ReaderOption that limits how deeply maps and
+// arrays may be nested when they are decoded into empty interfaces, e.g.,
+// when decoding into a map[string]any. Decoding returns an error if a record
+// is nested more deeply than n. This guards against building very large
+// structures from untrusted databases. It is separate from the limit on the
+// depth of the data structure that always applies. A depth of zero or less
+// disables the check.
+func WithMaxDecodeDepth(n int) ReaderOption {
+	return func(options *readerOptions) {
+		options.decoder.maxDecodeDepth = n
+	}
+}
+
+// WithMaxFileSize returns a ReaderOption that limits the size of the
+// databases that may be opened to size bytes. Open checks the size of the file
+// before reading or memory mapping it, and FromBytes checks the length of the
+// buffer. This is intended as a guard when opening untrusted databases. A
+// size of zero or less disables the check.
+func WithMaxFileSize(size int64) ReaderOption {
+	return func(options *readerOptions) {
+		options.maxFileSize = size
+	}
+}
+
+func newReaderOptions(options []ReaderOption) *readerOptions {
+	opts := &readerOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	return opts
+}
+
+func (o *readerOptions) checkFileSize(size int64) error {
+	if o.maxFileSize > 0 && size > o.maxFileSize {
+		return fmt.Errorf(
+			"error opening database: size of %d bytes exceeds the maximum of %d bytes",
+			size,
+			o.maxFileSize,
+		)
+	}
+	return nil
+}
 
-	rvMetadata := reflect.ValueOf(&metadata)
-	_, err := metadataDecoder.decode(0, rvMetadata, 0)
+// OpenFS reads the named MaxMind DB file from fsys, e.g., an embed.FS, and
+// returns a Reader for it. The file is read into memory rather than memory
+// mapped, as with FromBytes, so Close does not need to release a mapping.
+func OpenFS(fsys fs.FS, name string, options ...ReaderOption) (*Reader, error) {
+	stats, err := fs.Stat(fsys, name)
 	if err != nil {
 		return nil, err
 	}
+	if err := newReaderOptions(options).checkFileSize(stats.Size()); err != nil {
+		return nil, err
+	}
 
-	searchTreeSize := metadata.NodeCount * (metadata.RecordSize / 4)
-	dataSectionStart := searchTreeSize + dataSectionSeparatorSize
-	dataSectionEnd := uint(metadataStart - len(metadataStartMarker))
-	if dataSectionStart > dataSectionEnd {
-		return nil, newInvalidDatabaseError("the MaxMind DB contains invalid metadata")
+	buffer, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromBytes(buffer, options...)
+}
+
+// FromBytes takes a byte slice corresponding to a MaxMind DB file and returns
+// a Reader structure or an error.
+func FromBytes(buffer []byte, options ...ReaderOption) (*Reader, error) {
+	opts := newReaderOptions(options)
+	if err := opts.checkFileSize(int64(len(buffer))); err != nil {
+		return nil, err
 	}
+
+	metadata, markerStart, err := findMetadata(buffer, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metadata.Description) == 0 {
+		opts.decoder.logger.log("the MaxMind DB metadata does not contain a description")
+	}
+
+	searchTreeSize := metadata.NodeCount * (metadata.RecordSize / 4)
 	d := decoder{
-		buffer: buffer[searchTreeSize+dataSectionSeparatorSize : metadataStart-len(metadataStartMarker)],
+		buffer: buffer[searchTreeSize+dataSectionSeparatorSize : markerStart],
+		opts:   opts.decoder,
 	}
 
 	nodeBuffer := buffer[:searchTreeSize]
@@ -89,12 +304,14 @@ func FromBytes(buffer []byte) (*Reader, error) {
 	}
 
 	reader := &Reader{
-		buffer:         buffer,
-		nodeReader:     nodeReader,
-		decoder:        d,
-		Metadata:       metadata,
-		ipv4Start:      0,
-		nodeOffsetMult: metadata.RecordSize / 4,
+		buffer:              buffer,
+		nodeReader:          nodeReader,
+		decoder:             d,
+		Metadata:            metadata,
+		ipv4Start:           0,
+		nodeOffsetMult:      metadata.RecordSize / 4,
+		normalizeIPv4Prefix: opts.normalizeIPv4Prefix,
+		ipv4MappedMode:      opts.ipv4MappedMode,
 	}
 
 	reader.setIPv4Start()
@@ -102,6 +319,108 @@ func FromBytes(buffer []byte) (*Reader, error) {
 	return reader, err
 }
 
+// findMetadata locates and decodes the metadata section, returning the
+// metadata and the offset of the metadata start marker. The marker is
+// normally the last occurrence of the marker bytes in the file. However, the
+// bytes may also appear by chance, e.g., in a string in the metadata or in
+// trailing bytes after it. If the metadata after the last marker cannot be
+// decoded or is inconsistent with the size of the file, earlier occurrences
+// are tried in turn. If none are valid, the error for the last occurrence is
+// returned.
+//
+// bufferStart is the offset of buffer within the file. It is non-zero if
+// only the end of the file was read, as with OpenMetadata.
+func findMetadata(buffer []byte, bufferStart int) (Metadata, int, error) {
+	markerStart := bytes.LastIndex(buffer, metadataStartMarker)
+	if markerStart == -1 {
+		return Metadata{}, 0, newInvalidDatabaseError(
+			"error opening database: invalid MaxMind DB file",
+		)
+	}
+
+	var firstErr error
+	for markerStart != -1 {
+		metadata, err := decodeMetadata(buffer, bufferStart, markerStart)
+		if err == nil {
+			return metadata, markerStart, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		markerStart = bytes.LastIndex(buffer[:markerStart], metadataStartMarker)
+	}
+	return Metadata{}, 0, firstErr
+}
+
+// decodeMetadata decodes the metadata following the marker at markerStart
+// and checks that the sections it describes fit before the marker.
+func decodeMetadata(buffer []byte, bufferStart, markerStart int) (Metadata, error) {
+	metadataDecoder := decoder{buffer: buffer[markerStart+len(metadataStartMarker):]}
+
+	var metadata Metadata
+	if _, err := metadataDecoder.decode(0, reflect.ValueOf(&metadata), 0); err != nil {
+		return Metadata{}, err
+	}
+
+	switch metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return Metadata{}, newInvalidDatabaseError("unknown record size: %d", metadata.RecordSize)
+	}
+
+	searchTreeSize := metadata.NodeCount * (metadata.RecordSize / 4)
+	dataSectionStart := searchTreeSize + dataSectionSeparatorSize
+	if dataSectionStart > uint(bufferStart+markerStart) {
+		return Metadata{}, newInvalidDatabaseError("the MaxMind DB contains invalid metadata")
+	}
+	return metadata, nil
+}
+
+// Comparable returns nil if the networks and records of r and other may be
+// meaningfully compared, e.g., when comparing two builds of the same
+// database. Otherwise, it returns an error describing the difference. The
+// databases must have the same IP version and database type.
+func (r *Reader) Comparable(other *Reader) error {
+	if r.buffer == nil || other.buffer == nil {
+		return errors.New("cannot compare a closed database")
+	}
+	if r.Metadata.IPVersion != other.Metadata.IPVersion {
+		return fmt.Errorf(
+			"cannot compare IPv%d database with IPv%d database",
+			r.Metadata.IPVersion,
+			other.Metadata.IPVersion,
+		)
+	}
+	if r.Metadata.DatabaseType != other.Metadata.DatabaseType {
+		return fmt.Errorf(
+			"cannot compare %q database with %q database",
+			r.Metadata.DatabaseType,
+			other.Metadata.DatabaseType,
+		)
+	}
+	return nil
+}
+
+// RawMetadata decodes the entire metadata section into a map. Unlike the
+// Metadata field, this includes any keys that are not part of the MaxMind DB
+// specification, such as extensions added by the database's vendor.
+func (r *Reader) RawMetadata() (map[string]any, error) {
+	if r.buffer == nil {
+		return nil, errors.New("cannot call RawMetadata on a closed database")
+	}
+	_, markerStart, err := findMetadata(r.buffer, 0)
+	if err != nil {
+		return nil, err
+	}
+	metadataDecoder := decoder{buffer: r.buffer[markerStart+len(metadataStartMarker):]}
+
+	var metadata map[string]any
+	if _, err := metadataDecoder.decode(0, reflect.ValueOf(&metadata), 0); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
 func (r *Reader) setIPv4Start() {
 	if r.Metadata.IPVersion != 6 {
 		r.ipv4StartBitDepth = 96
@@ -126,28 +445,397 @@ func (r *Reader) Lookup(ip netip.Addr) Result {
 		return Result{err: errors.New("cannot call Lookup on a closed database")}
 	}
 	pointer, prefixLen, err := r.lookupPointer(ip)
+	return r.result(ip, pointer, prefixLen, err)
+}
+
+// result returns the Result for a lookup of ip that resolved to pointer.
+func (r *Reader) result(ip netip.Addr, pointer uint, prefixLen int, err error) Result {
 	if err != nil {
 		return Result{
-			ip:        ip,
-			prefixLen: uint8(prefixLen),
-			err:       err,
+			ip:                  ip,
+			prefixLen:           uint8(prefixLen),
+			normalizeIPv4Prefix: r.normalizeIPv4Prefix,
+			err:                 err,
 		}
 	}
 	if pointer == 0 {
 		return Result{
-			ip:        ip,
-			prefixLen: uint8(prefixLen),
-			offset:    notFound,
+			ip:                  ip,
+			prefixLen:           uint8(prefixLen),
+			normalizeIPv4Prefix: r.normalizeIPv4Prefix,
+			offset:              notFound,
 		}
 	}
 	offset, err := r.resolveDataPointer(pointer)
 	return Result{
-		decoder:   r.decoder,
-		ip:        ip,
-		offset:    uint(offset),
-		prefixLen: uint8(prefixLen),
-		err:       err,
+		decoder:             r.decoder,
+		ip:                  ip,
+		offset:              uint(offset),
+		prefixLen:           uint8(prefixLen),
+		err:                 err,
+		normalizeIPv4Prefix: r.normalizeIPv4Prefix,
+	}
+}
+
+// LookupBatch looks up each of ips and returns their Results in the same
+// order as ips. The addresses are looked up in sorted order so that the
+// search tree nodes shared by consecutive addresses are only read once. For
+// large batches of addresses that are close together, this is faster than
+// calling Lookup for each address.
+func (r *Reader) LookupBatch(ips []netip.Addr) []Result {
+	results := make([]Result, len(ips))
+	if r.buffer == nil {
+		for i, ip := range ips {
+			results[i] = Result{
+				ip:  ip,
+				err: errors.New("cannot call LookupBatch on a closed database"),
+			}
+		}
+		return results
+	}
+
+	order := make([]int, len(ips))
+	for i := range order {
+		order[i] = i
 	}
+	slices.SortFunc(order, func(a, b int) int {
+		return ips[a].Compare(ips[b])
+	})
+
+	nodeCount := r.Metadata.NodeCount
+	// path[i] is the node at bit depth i on the path taken by the previous
+	// address, for bit depths up to prevDepth.
+	var path [129]uint
+	var prev [16]byte
+	prevDepth := -1
+	prevIs4 := false
+	for _, idx := range order {
+		ip := ips[idx]
+		addr := r.lookupAddr(ip)
+		if r.Metadata.IPVersion == 4 && addr.Is6() {
+			results[idx] = r.Lookup(ip)
+			continue
+		}
+
+		ip16 := addr.As16()
+		i := 0
+		node := uint(0)
+		if addr.Is4() {
+			i = r.ipv4StartBitDepth
+			node = r.ipv4Start
+		}
+		if prevDepth >= 0 && addr.Is4() == prevIs4 {
+			i = max(i, min(commonPrefixLen(prev, ip16), prevDepth))
+			node = path[i]
+		}
+		path[i] = node
+		for ; i < 128 && node < nodeCount; i++ {
+			node = r.readChild(node, ipBit(ip16, i))
+			path[i+1] = node
+		}
+		prev, prevDepth, prevIs4 = ip16, i, addr.Is4()
+
+		pointer, err := r.recordPointer(node)
+		results[idx] = r.result(ip, pointer, i, err)
+	}
+	return results
+}
+
+// commonPrefixLen returns the number of leading bits shared by a and b.
+func commonPrefixLen(a, b [16]byte) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return 128
+}
+
+// LookupContext retrieves the database record for ip like Lookup, but stops
+// if ctx is canceled while the search tree is being traversed. This is
+// useful when the database is on slow storage, e.g., a network file system,
+// where reading the search tree may block on page faults. If ctx is done, the
+// Result's Err wraps ctx.Err().
+func (r *Reader) LookupContext(ctx context.Context, ip netip.Addr) Result {
+	if r.buffer == nil {
+		return Result{err: errors.New("cannot call LookupContext on a closed database")}
+	}
+	addr := r.lookupAddr(ip)
+	if r.Metadata.IPVersion == 4 && addr.Is6() {
+		return r.Lookup(ip)
+	}
+
+	i := 0
+	node := uint(0)
+	if addr.Is4() {
+		i = r.ipv4StartBitDepth
+		node = r.ipv4Start
+	}
+	nodeCount := r.Metadata.NodeCount
+	ip16 := addr.As16()
+	for ; i < 128 && node < nodeCount; i++ {
+		// Checking on every bit would make the lookup noticeably slower.
+		if i%lookupContextCheckBits == 0 {
+			if err := ctx.Err(); err != nil {
+				return r.result(ip, 0, i, fmt.Errorf("error looking up '%s': %w", ip, err))
+			}
+		}
+		node = r.readChild(node, ipBit(ip16, i))
+	}
+	if err := ctx.Err(); err != nil {
+		return r.result(ip, 0, i, fmt.Errorf("error looking up '%s': %w", ip, err))
+	}
+
+	pointer, err := r.recordPointer(node)
+	return r.result(ip, pointer, i, err)
+}
+
+// lookupContextCheckBits is how many bits of the address LookupContext
+// traverses between checks of its context.
+const lookupContextCheckBits = 16
+
+// Contains reports whether the database has a record for ip. It is faster
+// than Lookup when the record itself is not needed, e.g., when checking an
+// allowlist or denylist.
+func (r *Reader) Contains(ip netip.Addr) (bool, error) {
+	if r.buffer == nil {
+		return false, errors.New("cannot call Contains on a closed database")
+	}
+	pointer, _, err := r.lookupPointer(ip)
+	if err != nil {
+		return false, err
+	}
+	return pointer != 0, nil
+}
+
+// NetworkKey returns the string form of the network containing ip, as with
+// Lookup(ip).Prefix().String(), without decoding the record. As every
+// address in the network has the same record, the key is suitable for
+// caching data derived from the record. Networks without a record also have
+// a key.
+func (r *Reader) NetworkKey(ip netip.Addr) (string, error) {
+	if r.buffer == nil {
+		return "", errors.New("cannot call NetworkKey on a closed database")
+	}
+	result := r.Lookup(ip)
+	if err := result.Err(); err != nil {
+		return "", err
+	}
+	return result.Prefix().String(), nil
+}
+
+// LookupDeepestSubdivision returns the ISO code of the most specific
+// subdivision for ip, i.e., the last element of the subdivisions array in
+// GeoIP2 and GeoLite2 City and Enterprise databases. The boolean is false if
+// there is no record for ip or the record has no subdivisions.
+func (r *Reader) LookupDeepestSubdivision(ip netip.Addr) (isoCode string, found bool, err error) {
+	found, err = r.Lookup(ip).DecodePathExists(&isoCode, "subdivisions", -1, "iso_code")
+	return isoCode, found, err
+}
+
+// LookupBytes retrieves the database record for ip, which must be a 4-byte
+// IPv4 address or a 16-byte IPv6 address, such as those found in packet
+// headers. It is otherwise the same as Lookup. As with netip.AddrFromSlice,
+// a 16-byte IPv4-mapped IPv6 address is looked up as an IPv6 address.
+func (r *Reader) LookupBytes(ip []byte) Result {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return Result{
+			err: fmt.Errorf(
+				"error looking up IP address: expected 4 or 16 bytes but got %d",
+				len(ip),
+			),
+		}
+	}
+	return r.Lookup(addr)
+}
+
+// LookupAs looks up ip and decodes the record into a new value of type T. The
+// boolean is the same as Result.Found. If the record is not found, the zero
+// value of T is returned with a nil error.
+//
+// Example usage:
+//
+//	city, found, err := maxminddb.LookupAs[City](reader, ip)
+func LookupAs[T any](r *Reader, ip netip.Addr) (T, bool, error) {
+	var v T
+	result := r.Lookup(ip)
+	if !result.Found() {
+		return v, false, result.Err()
+	}
+	if err := result.Decode(&v); err != nil {
+		var zero T
+		return zero, true, err
+	}
+	return v, true, nil
+}
+
+// LookupPath looks up ip and decodes the value found by following path, as
+// with Result.DecodePath, into a new value of type T. The boolean is false and
+// the zero value of T is returned if the IP address or the path is not found
+// in the database.
+//
+// Example usage:
+//
+//	isoCode, found, err := maxminddb.LookupPath[string](
+//		reader, ip, "country", "iso_code",
+//	)
+func LookupPath[T any](r *Reader, ip netip.Addr, path ...any) (T, bool, error) {
+	var v T
+	result := r.Lookup(ip)
+	if !result.Found() {
+		return v, false, result.Err()
+	}
+	found, err := result.DecodePathExists(&v, path...)
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	return v, found, nil
+}
+
+// LookupAtomic looks up ip, decodes the record into a new T, and atomically
+// stores a pointer to it in dst. It returns the network of the record and
+// whether it was found. dst is only updated if the record was found and
+// decoded successfully. This is intended for concurrent caches that publish
+// the most recently decoded record.
+func LookupAtomic[T any](r *Reader, ip netip.Addr, dst *atomic.Pointer[T]) (netip.Prefix, bool, error) {
+	result := r.Lookup(ip)
+	if !result.Found() {
+		return result.Prefix(), false, result.Err()
+	}
+	v := new(T)
+	if err := result.Decode(v); err != nil {
+		return result.Prefix(), false, err
+	}
+	dst.Store(v)
+	return result.Prefix(), true, nil
+}
+
+// DecodeOffsets decodes the value at each of offsets, e.g., those returned by
+// Result.Offset, into a new value of type T. The returned slice has the same
+// length as offsets, with the value for each offset at the same index. If any
+// of the values cannot be decoded, the error for the first such offset is
+// returned.
+//
+// Example usage:
+//
+//	cities, err := maxminddb.DecodeOffsets[City](reader, offsets)
+func DecodeOffsets[T any](r *Reader, offsets []uintptr) ([]T, error) {
+	values := make([]T, len(offsets))
+	for i, offset := range offsets {
+		if err := r.LookupOffset(offset).Decode(&values[i]); err != nil {
+			return nil, fmt.Errorf("decoding offset %d: %w", offset, err)
+		}
+	}
+	return values, nil
+}
+
+// LookupBroadest retrieves the database record for ip like Lookup, but the
+// Result's Prefix is the broadest network containing ip in which every
+// address resolves to the same record, rather than the most specific network
+// in the search tree. This is useful when many adjacent networks in the
+// search tree share a record.
+//
+// For IPv4 addresses, the network is never broadened beyond the IPv4
+// subtree. As this may walk large parts of the search tree, it is
+// considerably slower than Lookup.
+func (r *Reader) LookupBroadest(ip netip.Addr) Result {
+	result := r.Lookup(ip)
+	if result.err != nil || result.offset == notFound {
+		return result
+	}
+
+	addr := r.lookupAddr(ip)
+	startBit := 0
+	node := uint(0)
+	if addr.Is4() {
+		startBit = r.ipv4StartBitDepth
+		node = r.ipv4Start
+	}
+	prefixLen := int(result.prefixLen)
+	ip16 := addr.As16()
+
+	// The nodes on the path to the record, indexed by bit depth less
+	// startBit.
+	nodes := make([]uint, 0, prefixLen-startBit)
+	for i := startBit; i < prefixLen; i++ {
+		nodes = append(nodes, node)
+		node = r.readChild(node, ipBit(ip16, i))
+	}
+	pointer := node
+
+	for i := prefixLen - 1; i >= startBit; i-- {
+		sibling := r.readChild(nodes[i-startBit], 1-ipBit(ip16, i))
+		ok, err := r.subtreeResolvesTo(sibling, pointer, i+1)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		if !ok {
+			break
+		}
+		result.prefixLen = uint8(i)
+	}
+	return result
+}
+
+// SameRecord reports whether a and b resolve to the same record. The
+// records' offsets in the data section are compared without decoding them.
+// Writers normally store identical records only once, but records with the
+// same contents that were stored separately are not considered the same. If
+// neither address has a record, true is returned.
+func (r *Reader) SameRecord(a, b netip.Addr) (bool, error) {
+	resultA := r.Lookup(a)
+	if err := resultA.Err(); err != nil {
+		return false, err
+	}
+	resultB := r.Lookup(b)
+	if err := resultB.Err(); err != nil {
+		return false, err
+	}
+	return resultA.offset == resultB.offset, nil
+}
+
+// subtreeResolvesTo reports whether every address under node, which is at
+// bit depth depth, resolves to pointer.
+func (r *Reader) subtreeResolvesTo(node, pointer uint, depth int) (bool, error) {
+	if node == pointer {
+		return true, nil
+	}
+	if node >= r.Metadata.NodeCount {
+		return false, nil
+	}
+	if depth >= 128 {
+		return false, newInvalidDatabaseError("invalid search tree below bit %d", depth)
+	}
+	for bit := range uint(2) {
+		ok, err := r.subtreeResolvesTo(r.readChild(node, bit), pointer, depth+1)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (r *Reader) readChild(node, bit uint) uint {
+	offset := node * r.nodeOffsetMult
+	if bit == 0 {
+		return r.nodeReader.readLeft(offset)
+	}
+	return r.nodeReader.readRight(offset)
+}
+
+func ipBit(ip16 [16]byte, i int) uint {
+	return uint(1) & (uint(ip16[i>>3]) >> (7 - (i % 8)))
+}
+
+// LookupFunc returns r.Lookup as a function. This allows lookups to be
+// passed to code that should not have access to the rest of the Reader. As
+// with Lookup, the function may be called concurrently from multiple
+// goroutines. It must not be called after the Reader is closed.
+func (r *Reader) LookupFunc() func(netip.Addr) Result {
+	return r.Lookup
 }
 
 // LookupOffset returns the Result for the specified offset. Note that
@@ -160,9 +848,49 @@ func (r *Reader) LookupOffset(offset uintptr) Result {
 	return Result{decoder: r.decoder, offset: uint(offset)}
 }
 
+// DataRecords returns an iterator over the values stored in the data section,
+// in the order that they appear in the file. Unlike Networks, this does not
+// traverse the search tree. It yields each top-level value exactly once,
+// including values that are only referenced through pointers from other
+// values as well as values that no network points to.
+//
+// As with LookupOffset, the netip.Prefix returned by the Result will be
+// invalid. If an error is encountered, a Result with the error is yielded
+// and iteration stops.
+func (r *Reader) DataRecords() iter.Seq[Result] {
+	return func(yield func(Result) bool) {
+		if r.buffer == nil {
+			yield(Result{err: errors.New("cannot call DataRecords on a closed database")})
+			return
+		}
+
+		bufferLen := uint(len(r.decoder.buffer))
+		for offset := uint(0); offset < bufferLen; {
+			next, err := r.decoder.nextValueOffset(offset, 1)
+			if err == nil && next <= offset {
+				err = newInvalidDatabaseError(
+					"data section offset unexpectedly went from %v to %v",
+					offset,
+					next,
+				)
+			}
+			if err != nil {
+				yield(Result{err: err})
+				return
+			}
+
+			if !yield(Result{decoder: r.decoder, offset: offset}) {
+				return
+			}
+			offset = next
+		}
+	}
+}
+
 var zeroIP = netip.MustParseAddr("::")
 
 func (r *Reader) lookupPointer(ip netip.Addr) (uint, int, error) {
+	ip = r.lookupAddr(ip)
 	if r.Metadata.IPVersion == 4 && ip.Is6() {
 		return 0, 0, fmt.Errorf(
 			"error looking up '%s': you attempted to look up an IPv6 address in an IPv4-only database",
@@ -171,16 +899,32 @@ func (r *Reader) lookupPointer(ip netip.Addr) (uint, int, error) {
 	}
 
 	node, prefixLength := r.traverseTree(ip, 0, 128)
+	pointer, err := r.recordPointer(node)
+	return pointer, prefixLength, err
+}
 
+// recordPointer returns the data section pointer for the node at which a
+// traversal of the search tree stopped. Zero is returned if the record is
+// empty.
+func (r *Reader) recordPointer(node uint) (uint, error) {
 	nodeCount := r.Metadata.NodeCount
 	if node == nodeCount {
 		// Record is empty
-		return 0, prefixLength, nil
+		return 0, nil
 	} else if node > nodeCount {
-		return node, prefixLength, nil
+		return node, nil
 	}
 
-	return 0, prefixLength, newInvalidDatabaseError("invalid node in search tree")
+	return 0, newInvalidDatabaseError("invalid node in search tree")
+}
+
+// lookupAddr returns the address to traverse the search tree with when
+// looking up ip, taking WithIPv4MappedMode into account.
+func (r *Reader) lookupAddr(ip netip.Addr) netip.Addr {
+	if r.ipv4MappedMode == IPv4MappedAsIPv4 {
+		return ip.Unmap()
+	}
+	return ip
 }
 
 func (r *Reader) traverseTree(ip netip.Addr, node uint, stopBit int) (uint, int) {
@@ -3,20 +3,31 @@
 
 package maxminddb
 
-import "io/ioutil"
+import (
+	"io/ioutil"
+	"os"
+)
 
 // Open takes a string path to a MaxMind DB file and returns a Reader
 // structure or an error. The database file is opened using a memory map
 // on supported platforms. On platforms without memory map support, such
 // as WebAssembly or Google App Engine, the database is loaded into memory.
 // Use the Close method on the Reader object to return the resources to the system.
-func Open(file string) (*Reader, error) {
+func Open(file string, options ...ReaderOption) (*Reader, error) {
+	stats, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := newReaderOptions(options).checkFileSize(stats.Size()); err != nil {
+		return nil, err
+	}
+
 	bytes, err := ioutil.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
 
-	return FromBytes(bytes)
+	return FromBytes(bytes, options...)
 }
 
 // Close returns the resources used by the database to the system.
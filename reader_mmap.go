@@ -13,7 +13,9 @@ import (
 // on supported platforms. On platforms without memory map support, such
 // as WebAssembly or Google App Engine, the database is loaded into memory.
 // Use the Close method on the Reader object to return the resources to the system.
-func Open(file string) (*Reader, error) {
+func Open(file string, options ...ReaderOption) (*Reader, error) {
+	opts := newReaderOptions(options)
+
 	mapFile, err := os.Open(file)
 	if err != nil {
 		_ = mapFile.Close()
@@ -26,6 +28,11 @@ func Open(file string) (*Reader, error) {
 		return nil, err
 	}
 
+	if err := opts.checkFileSize(stats.Size()); err != nil {
+		_ = mapFile.Close()
+		return nil, err
+	}
+
 	fileSize := int(stats.Size())
 	mmap, err := mmap(int(mapFile.Fd()), fileSize)
 	if err != nil {
@@ -39,7 +46,7 @@ func Open(file string) (*Reader, error) {
 		return nil, err
 	}
 
-	reader, err := FromBytes(mmap)
+	reader, err := FromBytes(mmap, options...)
 	if err != nil {
 		//nolint:errcheck // we prefer to return the original error
 		munmap(mmap)
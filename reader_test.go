@@ -1,15 +1,25 @@
 package maxminddb
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/fs"
 	"math/big"
 	"math/rand"
 	"net"
 	"net/netip"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
@@ -209,160 +219,1846 @@ func TestLookupNetwork(t *testing.T) {
 	}
 }
 
+func TestWithMaxFileSize(t *testing.T) {
+	file := testFile("GeoIP2-City-Test.mmdb")
+
+	_, err := Open(file, WithMaxFileSize(100))
+	require.ErrorContains(t, err, "exceeds the maximum of 100 bytes")
+
+	reader, err := Open(file, WithMaxFileSize(1<<30))
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+
+	buffer, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	_, err = FromBytes(buffer, WithMaxFileSize(100))
+	require.ErrorContains(t, err, "exceeds the maximum of 100 bytes")
+
+	reader, err = FromBytes(buffer, WithMaxFileSize(int64(len(buffer))))
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+}
+
+func TestLookupFunc(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	lookup := reader.LookupFunc()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				var isoCode string
+				err := lookup(netip.MustParseAddr("81.2.69.142")).
+					DecodePath(&isoCode, "country", "iso_code")
+				assert.NoError(t, err)
+				assert.Equal(t, "GB", isoCode)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLookupBatch(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-no-ipv4-search-tree.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-mixed-32.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			ips := []netip.Addr{
+				netip.MustParseAddr("::"),
+				netip.MustParseAddr("1.1.1.1"),
+				netip.MustParseAddr("1.1.1.1"),
+				netip.MustParseAddr("::ffff:1.1.1.1"),
+				netip.MustParseAddr("2001::1"),
+			}
+			for network := range reader.Networks(IncludeNetworksWithoutData) {
+				require.NoError(t, network.Err())
+				ips = append(ips, network.Prefix().Addr(), lastAddr(network.Prefix()))
+			}
+			r := rand.New(rand.NewSource(0))
+			r.Shuffle(len(ips), func(i, j int) { ips[i], ips[j] = ips[j], ips[i] })
+
+			results := reader.LookupBatch(ips)
+			require.Len(t, results, len(ips))
+			for i, ip := range ips {
+				assert.Equal(t, reader.Lookup(ip), results[i], ip)
+			}
+		})
+	}
+
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	results := reader.LookupBatch([]netip.Addr{
+		netip.MustParseAddr("::1"),
+		netip.MustParseAddr("1.1.1.1"),
+	})
+	require.ErrorContains(t, results[0].Err(), "IPv6 address in an IPv4-only database")
+	require.NoError(t, results[1].Err())
+	require.True(t, results[1].Found())
+
+	require.NoError(t, reader.Close())
+	for _, result := range reader.LookupBatch([]netip.Addr{netip.MustParseAddr("1.1.1.1")}) {
+		require.EqualError(t, result.Err(), "cannot call LookupBatch on a closed database")
+	}
+}
+
+func TestSameRecord(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	network := reader.Lookup(netip.MustParseAddr("81.2.69.142")).Prefix()
+	require.Less(t, network.Bits(), 32)
+
+	for _, test := range []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "81.2.69.142", b: "81.2.69.142", expected: true},
+		{a: network.Addr().String(), b: lastAddr(network).String(), expected: true},
+		{a: "81.2.69.142", b: "89.160.20.112", expected: false},
+		{a: "81.2.69.142", b: "1.1.1.1", expected: false},
+		{a: "1.1.1.1", b: "1.1.1.2", expected: true},
+	} {
+		same, err := reader.SameRecord(netip.MustParseAddr(test.a), netip.MustParseAddr(test.b))
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, same, "%s and %s", test.a, test.b)
+	}
+
+	ipv4Reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	defer ipv4Reader.Close()
+
+	_, err = ipv4Reader.SameRecord(netip.MustParseAddr("1.1.1.1"), netip.MustParseAddr("::1"))
+	require.Error(t, err)
+}
+
+func TestLookupBytes(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	for _, ip := range []string{"81.2.69.142", "2001:218::1", "1.1.1.1"} {
+		addr := netip.MustParseAddr(ip)
+		assert.Equal(t, reader.Lookup(addr), reader.LookupBytes(addr.AsSlice()), ip)
+	}
+
+	for _, ip := range [][]byte{nil, {81, 2, 69}, make([]byte, 5)} {
+		result := reader.LookupBytes(ip)
+		require.EqualError(
+			t,
+			result.Err(),
+			fmt.Sprintf("error looking up IP address: expected 4 or 16 bytes but got %d", len(ip)),
+		)
+		assert.False(t, result.Found())
+	}
+}
+
+func TestLookupContext(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	for _, ip := range []string{"81.2.69.142", "2001:218::1", "1.1.1.1"} {
+		addr := netip.MustParseAddr(ip)
+		assert.Equal(t, reader.Lookup(addr), reader.LookupContext(context.Background(), addr), ip)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := reader.LookupContext(ctx, netip.MustParseAddr("81.2.69.142"))
+	require.ErrorIs(t, result.Err(), context.Canceled)
+	assert.False(t, result.Found())
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	result = reader.LookupContext(ctx, netip.MustParseAddr("2001:218::1"))
+	require.ErrorIs(t, result.Err(), context.DeadlineExceeded)
+
+	require.NoError(t, reader.Close())
+	result = reader.LookupContext(context.Background(), netip.MustParseAddr("81.2.69.142"))
+	require.EqualError(t, result.Err(), "cannot call LookupContext on a closed database")
+}
+
+func TestContains(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	for _, ip := range []string{"81.2.69.142", "2001:218::1", "1.1.1.1", "::"} {
+		addr := netip.MustParseAddr(ip)
+		contains, err := reader.Contains(addr)
+		require.NoError(t, err)
+		assert.Equal(t, reader.Lookup(addr).Found(), contains, ip)
+	}
+	contains, err := reader.Contains(netip.MustParseAddr("81.2.69.142"))
+	require.NoError(t, err)
+	assert.True(t, contains)
+
+	require.NoError(t, reader.Close())
+	_, err = reader.Contains(netip.MustParseAddr("81.2.69.142"))
+	require.EqualError(t, err, "cannot call Contains on a closed database")
+
+	ipv4Reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	defer ipv4Reader.Close()
+
+	_, err = ipv4Reader.Contains(netip.MustParseAddr("::1"))
+	require.ErrorContains(t, err, "IPv6 address in an IPv4-only database")
+}
+
+func TestNetworkKey(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	for _, ip := range []string{"81.2.69.142", "81.2.69.160", "2001:218::1", "::ffff:81.2.69.142"} {
+		addr := netip.MustParseAddr(ip)
+		result := reader.Lookup(addr)
+		require.True(t, result.Found(), ip)
+		key, err := reader.NetworkKey(addr)
+		require.NoError(t, err)
+		assert.Equal(t, result.Prefix().String(), key, ip)
+	}
+
+	key, err := reader.NetworkKey(netip.MustParseAddr("81.2.69.142"))
+	require.NoError(t, err)
+	assert.Equal(t, "81.2.69.142/31", key)
+
+	require.NoError(t, reader.Close())
+	_, err = reader.NetworkKey(netip.MustParseAddr("81.2.69.142"))
+	require.EqualError(t, err, "cannot call NetworkKey on a closed database")
+}
+
+func TestLookupDeepestSubdivision(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	for ip, expected := range map[string]string{
+		"81.2.69.142":   "ENG",
+		"2.125.160.216": "WBK",
+	} {
+		isoCode, found, err := reader.LookupDeepestSubdivision(netip.MustParseAddr(ip))
+		require.NoError(t, err)
+		assert.True(t, found, ip)
+		assert.Equal(t, expected, isoCode, ip)
+	}
+
+	for _, ip := range []string{"1.1.1.1", "2001:218::1", "89.160.20.112"} {
+		isoCode, found, err := reader.LookupDeepestSubdivision(netip.MustParseAddr(ip))
+		require.NoError(t, err)
+		assert.False(t, found, ip)
+		assert.Empty(t, isoCode, ip)
+	}
+}
+
+func TestLookupAs(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	type city struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+
+	record, found, err := LookupAs[city](reader, netip.MustParseAddr("81.2.69.142"))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "GB", record.Country.ISOCode)
+
+	record, found, err = LookupAs[city](reader, netip.MustParseAddr("1.1.1.1"))
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Equal(t, city{}, record)
+
+	_, found, err = LookupAs[string](reader, netip.MustParseAddr("81.2.69.142"))
+	require.Error(t, err)
+	assert.True(t, found)
+}
+
+func TestDecodeWithoutPointerFollowing(t *testing.T) {
+	// "foo" at offset 0 followed by the record {"a": <pointer to 0>,
+	// "b": "bar"} at offset 4.
+	reader := twoNodeDatabase(t, "000016", "000016", "43666f6f"+"e241612000416243626172")
+	result := reader.Lookup(netip.MustParseAddr("1.1.1.1"))
+
+	var unfollowed map[string]any
+	require.NoError(t, result.DecodeWithoutPointerFollowing(&unfollowed))
+	assert.Equal(t, map[string]any{"a": uintptr(0), "b": "bar"}, unfollowed)
+
+	// Other decodes still follow pointers.
+	var followed map[string]any
+	require.NoError(t, result.Decode(&followed))
+	assert.Equal(t, map[string]any{"a": "foo", "b": "bar"}, followed)
+
+	var a string
+	require.NoError(t, reader.LookupOffset(unfollowed["a"].(uintptr)).Decode(&a))
+	assert.Equal(t, "foo", a)
+}
+
+func TestDecodeExcept(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("81.2.69.142"))
+
+	var expected fullCity
+	require.NoError(t, result.Decode(&expected))
+	require.NotZero(t, expected.City)
+
+	var city fullCity
+	require.NoError(t, result.DecodeExcept(&city, "city", "names"))
+	assert.Zero(t, city.City)
+	expected.City = city.City
+	// Only top-level keys are skipped.
+	assert.Equal(t, expected, city)
+
+	var record map[string]any
+	require.NoError(t, result.DecodeExcept(&record, "city", "location"))
+	assert.NotContains(t, record, "city")
+	assert.NotContains(t, record, "location")
+	assert.Contains(t, record, "country")
+
+	notFound := reader.Lookup(netip.MustParseAddr("1.1.1.1"))
+	require.NoError(t, notFound.DecodeExcept(&city, "city"))
+}
+
+func TestDecodeOffsets(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	type city struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+
+	var offsets []uintptr
+	for result := range reader.Networks() {
+		require.NoError(t, result.Err())
+		if len(offsets)%3 == 0 {
+			offsets = append(offsets, result.Offset())
+		}
+		offsets = append(offsets, result.Offset())
+	}
+	require.NotEmpty(t, offsets)
+
+	records, err := DecodeOffsets[city](reader, offsets)
+	require.NoError(t, err)
+	require.Len(t, records, len(offsets))
+	for i, offset := range offsets {
+		var expected city
+		require.NoError(t, reader.LookupOffset(offset).Decode(&expected))
+		assert.Equal(t, expected, records[i])
+	}
+
+	records, err = DecodeOffsets[city](reader, nil)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	_, err = DecodeOffsets[string](reader, offsets)
+	require.ErrorAs(t, err, &UnmarshalTypeError{})
+	require.ErrorContains(t, err, fmt.Sprintf("decoding offset %d", offsets[0]))
+
+	require.NoError(t, reader.Close())
+	_, err = DecodeOffsets[city](reader, offsets)
+	require.ErrorContains(t, err, "closed database")
+}
+
+func TestLookupPath(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	ip := netip.MustParseAddr("81.2.69.142")
+	isoCode, found, err := LookupPath[string](reader, ip, "country", "iso_code")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "GB", isoCode)
+
+	names, found, err := LookupPath[map[string]string](reader, ip, "subdivisions", 0, "names")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "England", names["en"])
+
+	isoCode, found, err = LookupPath[string](reader, ip, "country", "missing")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Empty(t, isoCode)
+
+	_, found, err = LookupPath[string](reader, netip.MustParseAddr("1.1.1.1"), "country", "iso_code")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	_, found, err = LookupPath[int](reader, ip, "country", "iso_code")
+	require.Error(t, err)
+	assert.False(t, found)
+
+	type country struct {
+		ISOCode string     `maxminddb:"iso_code"`
+		IP      netip.Addr `maxminddb:",addr"`
+	}
+	c, found, err := LookupPath[country](reader, ip, "country")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, country{ISOCode: "GB", IP: ip}, c)
+}
+
+func TestLookupAtomic(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	type country struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+
+	var dst atomic.Pointer[country]
+	prefix, found, err := LookupAtomic(reader, netip.MustParseAddr("81.2.69.142"), &dst)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "81.2.69.142/31", prefix.String())
+	first := dst.Load()
+	require.NotNil(t, first)
+	assert.Equal(t, "GB", first.Country.ISOCode)
+
+	// A new value is stored on each successful lookup.
+	_, found, err = LookupAtomic(reader, netip.MustParseAddr("81.2.69.142"), &dst)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.NotSame(t, first, dst.Load())
+
+	// The pointer is left unchanged if the record is not found.
+	current := dst.Load()
+	_, found, err = LookupAtomic(reader, netip.MustParseAddr("1.1.1.1"), &dst)
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Same(t, current, dst.Load())
+}
+
+// twoNodeDatabase returns a Reader for an IPv4 database with two nodes and
+// 24-bit records. The records for 0.0.0.0/2 and 64.0.0.0/2 are left and
+// right, which are hex encoded, and 128.0.0.0/1 is empty. The data section
+// is hex encoded in data. Data section offset n is record 18+n.
+func twoNodeDatabase(t *testing.T, left, right, data string, options ...ReaderOption) *Reader {
+	t.Helper()
+
+	searchTree := "000001000002" + left + right
+	metadata := "e95b62696e6172795f666f726d61745f6d616a6f725f76657273696f6ea1025b62696e6172" +
+		"795f666f726d61745f6d696e6f725f76657273696f6ea04b6275696c645f65706f636804026553f1004d" +
+		"64617461626173655f7479706544546573744b6465736372697074696f6ee142656e44546573744a6970" +
+		"5f76657273696f6ea104496c616e677561676573010442656e4a6e6f64655f636f756e74c1024b726563" +
+		"6f72645f73697a65a118"
+	buffer, err := hex.DecodeString(
+		searchTree + strings.Repeat("00", dataSectionSeparatorSize) + data +
+			hex.EncodeToString(metadataStartMarker) + metadata,
+	)
+	require.NoError(t, err)
+
+	reader, err := FromBytes(buffer, options...)
+	require.NoError(t, err)
+	return reader
+}
+
+func TestWithFloatAsString(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithFloatAsString)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("81.2.69.142"))
+
+	var longitude any
+	require.NoError(t, result.DecodePath(&longitude, "location", "longitude"))
+	assert.Equal(t, "-0.0931", longitude)
+
+	var location map[string]any
+	require.NoError(t, result.DecodePath(&location, "location"))
+	assert.Equal(t, "51.5142", location["latitude"])
+	assert.Equal(t, uint64(10), location["accuracy_radius"])
+
+	var f float64
+	require.NoError(t, result.DecodePath(&f, "location", "longitude"))
+	assert.InEpsilon(t, -0.0931, f, 1e-10)
+
+	decoderReader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"), WithFloatAsString)
+	require.NoError(t, err)
+	defer decoderReader.Close()
+
+	var record map[string]any
+	require.NoError(t, decoderReader.Lookup(netip.MustParseAddr("::1.1.1.0")).Decode(&record))
+	assert.Equal(t, "42.123456", record["double"])
+	assert.Equal(t, "1.1", record["float"])
+}
+
+func TestWithUntaggedLowercase(t *testing.T) {
+	type city struct {
+		Continent struct {
+			Code  string
+			Names map[string]string
+		}
+		Location struct {
+			Latitude  float64
+			Longitude float64
+			TimeZone  string `maxminddb:"time_zone"`
+		}
+	}
+	ip := netip.MustParseAddr("81.2.69.142")
+
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithUntaggedLowercase)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var record city
+	require.NoError(t, reader.Lookup(ip).Decode(&record))
+	assert.Equal(t, "EU", record.Continent.Code)
+	assert.Equal(t, "Europe", record.Continent.Names["en"])
+	assert.InEpsilon(t, 51.5142, record.Location.Latitude, 1e-10)
+	assert.InEpsilon(t, -0.0931, record.Location.Longitude, 1e-10)
+	assert.Equal(t, "Europe/London", record.Location.TimeZone)
+
+	// Without the option, only the tagged field's key matches and it is not
+	// reached as its parent does not match.
+	defaultReader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer defaultReader.Close()
+
+	var defaultRecord city
+	require.NoError(t, defaultReader.Lookup(ip).Decode(&defaultRecord))
+	assert.Equal(t, city{}, defaultRecord)
+}
+
+func TestWithIPv4MappedMode(t *testing.T) {
+	ipv4 := netip.MustParseAddr("81.2.69.142")
+	mapped := netip.MustParseAddr("::ffff:81.2.69.142")
+
+	for _, mode := range []IPv4MappedMode{IPv4MappedAsIPv6, IPv4MappedAsIPv4} {
+		t.Run(fmt.Sprintf("mode %d", mode), func(t *testing.T) {
+			reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithIPv4MappedMode(mode))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			ipv4Result := reader.Lookup(ipv4)
+			mappedResult := reader.Lookup(mapped)
+			require.NoError(t, mappedResult.Err())
+			assert.Equal(t, ipv4Result.Offset(), mappedResult.Offset())
+			assert.Equal(t, netip.MustParsePrefix("81.2.69.142/31"), ipv4Result.Prefix())
+			assert.Equal(
+				t,
+				netip.MustParsePrefix("::ffff:81.2.69.142/127"),
+				mappedResult.Prefix(),
+			)
+		})
+	}
+
+	// An IPv4 database has no IPv4-mapped network.
+	ipv4Only := netip.MustParseAddr("::ffff:1.1.1.1")
+
+	reader, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	require.ErrorContains(t, reader.Lookup(ipv4Only).Err(), "IPv6 address in an IPv4-only database")
+
+	reader, err = Open(
+		testFile("MaxMind-DB-test-ipv4-24.mmdb"),
+		WithIPv4MappedMode(IPv4MappedAsIPv4),
+	)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	for _, result := range []Result{
+		reader.Lookup(ipv4Only),
+		reader.LookupContext(context.Background(), ipv4Only),
+		reader.LookupBatch([]netip.Addr{ipv4Only})[0],
+	} {
+		require.NoError(t, result.Err())
+		assert.True(t, result.Found())
+		assert.Equal(t, reader.Lookup(netip.MustParseAddr("1.1.1.1")).Offset(), result.Offset())
+	}
+}
+
+type panickingUnmarshaler struct{}
+
+func (*panickingUnmarshaler) UnmarshalText([]byte) error {
+	panic("unexpected value")
+}
+
+func TestWithPanicRecovery(t *testing.T) {
+	var location struct {
+		TimeZone panickingUnmarshaler `maxminddb:"time_zone"`
+	}
+	ip := netip.MustParseAddr("81.2.69.142")
+
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Panics(t, func() {
+		_ = reader.Lookup(ip).DecodePath(&location, "location")
+	})
+
+	reader, err = Open(testFile("GeoIP2-City-Test.mmdb"), WithPanicRecovery)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	err = reader.Lookup(ip).DecodePath(&location, "location")
+	require.ErrorAs(t, err, &InvalidDatabaseError{})
+	require.EqualError(t, err, "panic while decoding: unexpected value")
+
+	found, err := reader.Lookup(ip).DecodePathExists(&location, "location")
+	require.ErrorAs(t, err, &InvalidDatabaseError{})
+	assert.False(t, found)
+
+	var record struct {
+		Location struct {
+			TimeZone panickingUnmarshaler `maxminddb:"time_zone"`
+		} `maxminddb:"location"`
+	}
+	require.ErrorAs(t, reader.Lookup(ip).Decode(&record), &InvalidDatabaseError{})
+
+	type subdivision struct {
+		ISOCode panickingUnmarshaler `maxminddb:"iso_code"`
+	}
+	_, err = DecodeSliceInto[subdivision](reader.Lookup(ip), nil, "subdivisions")
+	require.ErrorAs(t, err, &InvalidDatabaseError{})
+
+	names, err := DecodeMapValues[panickingUnmarshaler](reader.Lookup(ip), "country", "names")
+	require.NoError(t, err)
+	var yielded int
+	for range names {
+		yielded++
+	}
+	assert.Equal(t, 1, yielded)
+
+	_, _, err = LookupPath[panickingUnmarshaler](reader, ip, "location", "time_zone")
+	require.ErrorAs(t, err, &InvalidDatabaseError{})
+
+	plan, err := NewDecodePlan(&record, map[string][]int{"location": {0}})
+	require.NoError(t, err)
+	require.ErrorAs(t, reader.Lookup(ip).DecodeWithPlan(&record, plan), &InvalidDatabaseError{})
+
+	err = reader.NetworksColumnar(
+		[]ColumnSelector{{
+			Path: []any{"location", "time_zone"},
+			Type: reflect.TypeFor[panickingUnmarshaler](),
+		}},
+		func(netip.Prefix, []any) error { return nil },
+	)
+	require.ErrorAs(t, err, &InvalidDatabaseError{})
+
+	// A node count that is larger than the search tree makes the traversal
+	// read past the end of it.
+	reader.Metadata.NodeCount *= 1000
+	var networksErr error
+	require.NotPanics(t, func() {
+		for result := range reader.Networks() {
+			if err := result.Err(); err != nil {
+				networksErr = err
+			}
+		}
+	})
+	require.ErrorAs(t, networksErr, &InvalidDatabaseError{})
+	require.ErrorContains(t, networksErr, "panic while traversing networks")
+
+	// Panics from the loop body are not recovered.
+	reader.Metadata.NodeCount /= 1000
+	require.PanicsWithValue(t, "loop body", func() {
+		for range reader.Networks() {
+			panic("loop body")
+		}
+	})
+}
+
+func TestWithReportEmpty(t *testing.T) {
+	// 0.0.0.0/2 has the empty record {} and 64.0.0.0/2 has {"a": true}.
+	data := "e0" + "e141610107"
+
+	for _, reportEmpty := range []bool{false, true} {
+		t.Run(fmt.Sprintf("reportEmpty=%v", reportEmpty), func(t *testing.T) {
+			var options []ReaderOption
+			if reportEmpty {
+				options = append(options, WithReportEmpty)
+			}
+			reader := twoNodeDatabase(t, "000012", "000013", data, options...)
+
+			empty := reader.Lookup(netip.MustParseAddr("1.1.1.1"))
+			require.True(t, empty.Found())
+
+			var record map[string]bool
+			var a bool
+			if reportEmpty {
+				require.ErrorIs(t, empty.Decode(&record), ErrEmptyRecord)
+				require.ErrorIs(t, empty.DecodePath(&a, "a"), ErrEmptyRecord)
+			} else {
+				require.NoError(t, empty.Decode(&record))
+				require.NoError(t, empty.DecodePath(&a, "a"))
+			}
+			assert.Empty(t, record)
+			isEmpty, err := empty.PeekEmpty()
+			require.NoError(t, err)
+			assert.True(t, isEmpty)
+
+			nonEmpty := reader.Lookup(netip.MustParseAddr("64.1.1.1"))
+			isEmpty, err = nonEmpty.PeekEmpty()
+			require.NoError(t, err)
+			assert.False(t, isEmpty)
+			require.NoError(t, nonEmpty.Decode(&record))
+			assert.Equal(t, map[string]bool{"a": true}, record)
+			require.NoError(t, nonEmpty.DecodePath(&a, "a"))
+			assert.True(t, a)
+
+			notFound := reader.Lookup(netip.MustParseAddr("128.1.1.1"))
+			require.NoError(t, notFound.Decode(&record))
+			isEmpty, err = notFound.PeekEmpty()
+			require.NoError(t, err)
+			assert.False(t, isEmpty)
+		})
+	}
+}
+
+func TestLookupBroadest(t *testing.T) {
+	// 0.0.0.0/2 and 64.0.0.0/2 share the record "X".
+	reader := twoNodeDatabase(t, "000012", "000012", "4158")
+
+	ip := netip.MustParseAddr("1.2.3.4")
+	assert.Equal(t, "0.0.0.0/2", reader.Lookup(ip).Prefix().String())
+
+	result := reader.LookupBroadest(ip)
+	require.NoError(t, result.Err())
+	assert.Equal(t, "0.0.0.0/1", result.Prefix().String())
+	var record string
+	require.NoError(t, result.Decode(&record))
+	assert.Equal(t, "X", record)
+
+	notFound := reader.LookupBroadest(netip.MustParseAddr("128.0.0.1"))
+	require.NoError(t, notFound.Err())
+	assert.False(t, notFound.Found())
+	assert.Equal(t, "128.0.0.0/1", notFound.Prefix().String())
+
+	// Adjacent networks in the City database have distinct records, so the
+	// network is not broadened.
+	city, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer city.Close()
+	ip = netip.MustParseAddr("81.2.69.142")
+	assert.Equal(t, city.Lookup(ip).Prefix(), city.LookupBroadest(ip).Prefix())
+}
+
+func TestPrefixString(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv6-24.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("::2:0:1"))
+	require.True(t, result.Found())
+	assert.Equal(t, "::2:0:0/122", result.PrefixString(false))
+	assert.Equal(t, "0000:0000:0000:0000:0000:0002:0000:0000/122", result.PrefixString(true))
+
+	ipv4, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	defer ipv4.Close()
+
+	result = ipv4.Lookup(netip.MustParseAddr("1.1.1.3"))
+	require.True(t, result.Found())
+	assert.Equal(t, "1.1.1.2/31", result.PrefixString(false))
+	assert.Equal(t, "1.1.1.2/31", result.PrefixString(true))
+}
+
+func TestTreeDepth(t *testing.T) {
+	tests := []struct {
+		database string
+		ip       string
+		prefix   string
+		depth    int
+	}{
+		{"MaxMind-DB-test-ipv4-24.mmdb", "1.1.1.3", "1.1.1.2/31", 127},
+		{"MaxMind-DB-test-ipv6-24.mmdb", "::2:0:1", "::2:0:0/122", 122},
+		{"GeoIP2-City-Test.mmdb", "81.2.69.142", "81.2.69.142/31", 127},
+	}
+	for _, test := range tests {
+		reader, err := Open(testFile(test.database))
+		require.NoError(t, err)
+
+		result := reader.Lookup(netip.MustParseAddr(test.ip))
+		require.NoError(t, result.Err())
+		assert.Equal(t, test.prefix, result.Prefix().String())
+		assert.Equal(t, test.depth, result.TreeDepth())
+		if reader.Metadata.IPVersion == 4 {
+			// The tree of an IPv4 database starts at bit 96.
+			assert.Equal(t, 96, reader.ipv4StartBitDepth)
+			assert.Equal(t, result.Prefix().Bits(), result.TreeDepth()-96)
+		}
+
+		for result := range reader.NetworksWithin(netip.MustParsePrefix(test.prefix)) {
+			assert.Equal(t, test.depth, result.TreeDepth())
+		}
+		require.NoError(t, reader.Close())
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	var mu sync.Mutex
+	var messages []string
+	logger := func(msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		messages = append(messages, msg)
+	}
+
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"), WithLogger(logger))
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Empty(t, messages)
+
+	var record struct {
+		City struct {
+			GeoNameID uint `maxminddb:"geoname_id,bogus"`
+		} `maxminddb:"city"`
+	}
+	result := reader.Lookup(netip.MustParseAddr("81.2.69.142"))
+	for range 2 {
+		require.NoError(t, result.Decode(&record))
+	}
+	assert.Equal(t, uint(2643743), record.City.GeoNameID)
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], `ignoring unknown option "bogus" in maxminddb tag on field GeoNameID`)
+
+	// Without a logger, the unknown option is ignored silently.
+	noLogger, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer noLogger.Close()
+	require.NoError(t, noLogger.Lookup(netip.MustParseAddr("81.2.69.142")).Decode(&record))
+}
+
+func TestDecodingOmitemptyTag(t *testing.T) {
+	var messages []string
+	reader, err := Open(
+		testFile("GeoIP2-City-Test.mmdb"),
+		WithLogger(func(msg string) { messages = append(messages, msg) }),
+	)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var record struct {
+		Country struct {
+			ISOCode string `json:"iso_code,omitempty" maxminddb:"iso_code,omitempty"`
+		} `json:"country,omitempty" maxminddb:"country,omitempty"`
+	}
+	require.NoError(t, reader.Lookup(netip.MustParseAddr("81.2.69.142")).Decode(&record))
+	assert.Equal(t, "GB", record.Country.ISOCode)
+	assert.Empty(t, messages)
+}
+
+func TestIPv4PrefixNormalization(t *testing.T) {
+	ip := netip.MustParseAddr("200.0.2.1")
+
+	raw, err := Open(testFile("MaxMind-DB-no-ipv4-search-tree.mmdb"))
+	require.NoError(t, err)
+	defer raw.Close()
+
+	normalized, err := Open(
+		testFile("MaxMind-DB-no-ipv4-search-tree.mmdb"),
+		WithIPv4PrefixNormalization,
+	)
+	require.NoError(t, err)
+	defer normalized.Close()
+
+	rawResult := raw.Lookup(ip)
+	require.NoError(t, rawResult.Err())
+	assert.Equal(t, "::/64", rawResult.Prefix().String())
+
+	normalizedResult := normalized.Lookup(ip)
+	require.NoError(t, normalizedResult.Err())
+	assert.Equal(t, "0.0.0.0/0", normalizedResult.Prefix().String())
+	assert.True(t, normalizedResult.Prefix().Contains(ip))
+
+	var rawRecord, normalizedRecord any
+	require.NoError(t, rawResult.Decode(&rawRecord))
+	require.NoError(t, normalizedResult.Decode(&normalizedRecord))
+	assert.Equal(t, rawRecord, normalizedRecord)
+
+	// Databases with an IPv4 subtree are unaffected.
+	reader, err := Open(testFile("MaxMind-DB-test-mixed-24.mmdb"), WithIPv4PrefixNormalization)
+	require.NoError(t, err)
+	defer reader.Close()
+	assert.Equal(
+		t,
+		"1.1.1.1/32",
+		reader.Lookup(netip.MustParseAddr("1.1.1.1")).Prefix().String(),
+	)
+}
+
+func TestDataRecords(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	networkOffsets := map[uintptr]bool{}
+	for result := range reader.Networks() {
+		require.NoError(t, result.Err())
+		networkOffsets[result.Offset()] = true
+	}
+
+	records := 0
+	for result := range reader.DataRecords() {
+		require.NoError(t, result.Err())
+
+		var record any
+		require.NoError(t, result.Decode(&record))
+		records++
+		delete(networkOffsets, result.Offset())
+	}
+
+	assert.Empty(t, networkOffsets, "every network offset is a data record")
+	assert.Positive(t, records)
+}
+
 func TestDecodingToInterface(t *testing.T) {
 	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
-	require.NoError(t, err, "unexpected error while opening database: %v", err)
+	require.NoError(t, err, "unexpected error while opening database: %v", err)
+
+	var recordInterface any
+	err = reader.Lookup(netip.MustParseAddr("::1.1.1.0")).Decode(&recordInterface)
+	require.NoError(t, err, "unexpected error while doing lookup: %v", err)
+
+	checkDecodingToInterface(t, recordInterface)
+}
+
+func TestDecodingAnyUintWidthToUint64(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
+	for key, expected := range map[string]uint64{
+		"uint16": 100,
+		"uint32": 268435456,
+		"uint64": 1152921504606846976,
+	} {
+		var v uint64
+		require.NoError(t, result.DecodePath(&v, key))
+		assert.Equal(t, expected, v, key)
+	}
+
+	var v uint64
+	require.ErrorAs(t, result.DecodePath(&v, "utf8_string"), &UnmarshalTypeError{})
+}
+
+func TestDecodingUintToTime(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
+	var record struct {
+		Uint16 time.Time `maxminddb:"uint16"`
+		Uint32 time.Time `maxminddb:"uint32"`
+		Uint64 time.Time `maxminddb:"uint64"`
+	}
+	require.NoError(t, result.Decode(&record))
+	assert.Equal(t, time.Unix(100, 0), record.Uint16)
+	assert.Equal(t, time.Unix(268435456, 0), record.Uint32)
+	assert.Equal(t, time.Unix(1152921504606846976, 0), record.Uint64)
+
+	var v time.Time
+	require.ErrorAs(t, result.DecodePath(&v, "int32"), &UnmarshalTypeError{})
+	require.ErrorAs(t, result.DecodePath(&v, "double"), &UnmarshalTypeError{})
+	require.ErrorAs(t, result.DecodePath(&v, "uint128"), &UnmarshalTypeError{})
+
+	// The record for ::0.0.0.0 has zero values.
+	require.NoError(t, reader.Lookup(netip.MustParseAddr("::0.0.0.0")).DecodePath(&v, "uint32"))
+	assert.Equal(t, time.Unix(0, 0), v)
+}
+
+func TestDecodingUint128ToArrayAndString(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
+
+	var array [16]byte
+	require.NoError(t, result.DecodePath(&array, "uint128"))
+	assert.Equal(t, [16]byte{0x01}, array)
+
+	type id [16]byte
+	var named id
+	require.NoError(t, result.DecodePath(&named, "uint128"))
+	assert.Equal(t, id{0x01}, named)
+
+	var s string
+	require.NoError(t, result.DecodePath(&s, "uint128"))
+	assert.Equal(t, "1329227995784915872903807060280344576", s)
+
+	var record struct {
+		Uint128 *string `maxminddb:"uint128"`
+	}
+	require.NoError(t, result.Decode(&record))
+	require.NotNil(t, record.Uint128)
+	assert.Equal(t, "1329227995784915872903807060280344576", *record.Uint128)
+
+	var short [8]byte
+	require.ErrorAs(t, result.DecodePath(&short, "uint128"), &UnmarshalTypeError{})
+
+	// The record for ::0.0.0.0 has zero values.
+	zero := reader.Lookup(netip.MustParseAddr("::0.0.0.0"))
+	array[0] = 0xff
+	require.NoError(t, zero.DecodePath(&array, "uint128"))
+	assert.Equal(t, [16]byte{}, array)
+	require.NoError(t, zero.DecodePath(&s, "uint128"))
+	assert.Equal(t, "0", s)
+}
+
+func TestDecodingStringToBytes(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
+	var record struct {
+		UTF8String []byte `maxminddb:"utf8_string"`
+		Bytes      []byte `maxminddb:"bytes"`
+	}
+	require.NoError(t, result.Decode(&record))
+	assert.Equal(t, []byte("unicode! \u262f - \u266b"), record.UTF8String)
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x2a}, record.Bytes)
+
+	// Strings that are not valid UTF-8 are stored as is.
+	d := decoder{buffer: []byte{0x42, 0xff, 0xfe}}
+	var v []byte
+	_, err = d.decode(0, reflect.ValueOf(&v), 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xfe}, v)
+
+	var ints []int
+	require.ErrorAs(t, result.DecodePath(&ints, "utf8_string"), &UnmarshalTypeError{})
+}
+
+func TestDecodingInt32ToInt64(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
+	var v int64
+	require.NoError(t, result.DecodePath(&v, "int32"))
+	assert.Equal(t, int64(-268435456), v)
+
+	require.ErrorAs(t, result.DecodePath(&v, "utf8_string"), &UnmarshalTypeError{})
+	require.ErrorAs(t, result.DecodePath(&v, "double"), &UnmarshalTypeError{})
+}
+
+func TestResultCompareUint128(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
+
+	// The uint128 in this record is 2^120.
+	cmp, err := result.CompareUint128(1<<56, 0, "uint128")
+	require.NoError(t, err)
+	assert.Equal(t, 0, cmp)
+
+	cmp, err = result.CompareUint128(1<<56, 1, "uint128")
+	require.NoError(t, err)
+	assert.Equal(t, -1, cmp)
+
+	_, err = result.CompareUint128(0, 0, "missing")
+	require.Error(t, err)
+
+	_, err = result.CompareUint128(0, 0, "uint64")
+	require.Error(t, err)
+}
+
+func TestMetadataPointer(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-metadata-pointers.mmdb"))
+	require.NoError(t, err, "unexpected error while opening database: %v", err)
+
+	// The repeated strings in this database's metadata are stored once and
+	// referenced through pointers.
+	repeated := "Lots of pointers in metadata"
+	assert.Equal(t, repeated, reader.Metadata.DatabaseType)
+	assert.Equal(
+		t,
+		map[string]string{
+			"en": repeated,
+			"es": repeated,
+			"zh": repeated,
+		},
+		reader.Metadata.Description,
+	)
+	assert.Equal(t, []string{"en", "es", "zh"}, reader.Metadata.Languages)
+	require.NoError(t, reader.Close())
+}
+
+func TestOpenFS(t *testing.T) {
+	fsys := os.DirFS(filepath.Join("test-data", "test-data"))
+	reader, err := OpenFS(fsys, "GeoIP2-City-Test.mmdb")
+	require.NoError(t, err)
+	assert.False(t, reader.hasMappedFile)
+
+	var country string
+	require.NoError(
+		t,
+		reader.Lookup(netip.MustParseAddr("81.2.69.142")).DecodePath(&country, "country", "iso_code"),
+	)
+	assert.Equal(t, "GB", country)
+	require.NoError(t, reader.Close())
+
+	_, err = OpenFS(fsys, "does-not-exist.mmdb")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = OpenFS(fsys, "GeoIP2-City-Test.mmdb", WithMaxFileSize(100))
+	require.ErrorContains(t, err, "exceeds the maximum")
+
+	_, err = OpenFS(fstest.MapFS{"invalid.mmdb": {Data: []byte("not a database")}}, "invalid.mmdb")
+	require.ErrorContains(t, err, "invalid MaxMind DB file")
+}
+
+func TestOpenMetadata(t *testing.T) {
+	for _, file := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-decoder.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-ipv6-32.mmdb",
+		"MaxMind-DB-test-metadata-pointers.mmdb",
+	} {
+		t.Run(file, func(t *testing.T) {
+			reader, err := Open(testFile(file))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			metadata, err := OpenMetadata(testFile(file))
+			require.NoError(t, err)
+			assert.Equal(t, reader.Metadata, metadata)
+		})
+	}
+
+	// The test databases are smaller than the maximum metadata size, so it
+	// is reduced to check that only the end of the file is needed.
+	original, err := os.ReadFile(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	expected, err := FromBytes(original)
+	require.NoError(t, err)
+	assert.Equal(t, 128*1024+len(metadataStartMarker), metadataMaxSize)
+	require.Greater(t, len(original), 512)
+	metadata, err := openMetadata(testFile("GeoIP2-City-Test.mmdb"), 512)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Metadata, metadata)
+
+	_, err = OpenMetadata(testFile("does-not-exist.mmdb"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	invalid := filepath.Join(t.TempDir(), "invalid.mmdb")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a database"), 0o600))
+	_, err = OpenMetadata(invalid)
+	require.ErrorContains(t, err, "invalid MaxMind DB file")
+
+	// Metadata inconsistent with the size of the file is rejected, as with
+	// Open.
+	truncated := filepath.Join(t.TempDir(), "truncated.mmdb")
+	markerStart := bytes.LastIndex(original, metadataStartMarker)
+	require.NoError(t, os.WriteFile(truncated, original[markerStart:], 0o600))
+	_, err = OpenMetadata(truncated)
+	require.ErrorContains(t, err, "invalid metadata")
+}
+
+func TestRawMetadata(t *testing.T) {
+	original, err := os.ReadFile(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+
+	reader, err := FromBytes(original)
+	require.NoError(t, err)
+
+	raw, err := reader.RawMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(reader.Metadata.NodeCount), raw["node_count"])
+	assert.Equal(t, uint64(reader.Metadata.RecordSize), raw["record_size"])
+	assert.Equal(t, reader.Metadata.DatabaseType, raw["database_type"])
+	assert.Equal(t, map[string]any{"en": reader.Metadata.Description["en"]}, raw["description"])
+	assert.Contains(t, raw, "binary_format_major_version")
+	assert.Contains(t, raw, "build_epoch")
+	assert.NotContains(t, raw, "x_vendor")
+
+	// Add {"x_vendor": "acme"} to the metadata map.
+	metadataStart := bytes.LastIndex(original, metadataStartMarker) + len(metadataStartMarker)
+	extended := slices.Concat(original, []byte("\x48x_vendor\x44acme"))
+	require.Equal(t, byte(0xe0), extended[metadataStart]&0xe0)
+	extended[metadataStart]++
+
+	reader, err = FromBytes(extended)
+	require.NoError(t, err)
+
+	raw, err = reader.RawMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, "acme", raw["x_vendor"])
+	assert.Equal(t, reader.Metadata.DatabaseType, raw["database_type"])
+
+	require.NoError(t, reader.Close())
+	_, err = reader.RawMetadata()
+	require.EqualError(t, err, "cannot call RawMetadata on a closed database")
+}
+
+func TestComparable(t *testing.T) {
+	open := func(file string) *Reader {
+		reader, err := Open(testFile(file))
+		require.NoError(t, err)
+		t.Cleanup(func() { reader.Close() })
+		return reader
+	}
+	ipv4 := open("MaxMind-DB-test-ipv4-24.mmdb")
+	ipv4Other := open("MaxMind-DB-test-ipv4-32.mmdb")
+	ipv6 := open("MaxMind-DB-test-ipv6-24.mmdb")
+	city := open("GeoIP2-City-Test.mmdb")
+
+	require.NoError(t, ipv4.Comparable(ipv4Other))
+	require.NoError(t, city.Comparable(open("GeoIP2-City-Test.mmdb")))
+	require.EqualError(t, ipv4.Comparable(ipv6), "cannot compare IPv4 database with IPv6 database")
+	require.EqualError(
+		t,
+		city.Comparable(ipv6),
+		`cannot compare "GeoIP2-City" database with "Test" database`,
+	)
+
+	closed := open("MaxMind-DB-test-ipv4-24.mmdb")
+	require.NoError(t, closed.Close())
+	require.Error(t, ipv4.Comparable(closed))
+}
+
+func checkDecodingToInterface(t *testing.T, recordInterface any) {
+	record := recordInterface.(map[string]any)
+	assert.Equal(t, []any{uint64(1), uint64(2), uint64(3)}, record["array"])
+	assert.Equal(t, true, record["boolean"])
+	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x2a}, record["bytes"])
+	assert.InEpsilon(t, 42.123456, record["double"], 1e-10)
+	assert.InEpsilon(t, float32(1.1), record["float"], 1e-5)
+	assert.Equal(t, -268435456, record["int32"])
+	assert.Equal(t,
+		map[string]any{
+			"mapX": map[string]any{
+				"arrayX":       []any{uint64(7), uint64(8), uint64(9)},
+				"utf8_stringX": "hello",
+			},
+		},
+		record["map"],
+	)
+
+	assert.Equal(t, uint64(100), record["uint16"])
+	assert.Equal(t, uint64(268435456), record["uint32"])
+	assert.Equal(t, uint64(1152921504606846976), record["uint64"])
+	assert.Equal(t, "unicode! ☯ - ♫", record["utf8_string"])
+	bigInt := new(big.Int)
+	bigInt.SetString("1329227995784915872903807060280344576", 10)
+	assert.Equal(t, bigInt, record["uint128"])
+}
+
+type TestType struct {
+	Array      []uint         `maxminddb:"array"`
+	Boolean    bool           `maxminddb:"boolean"`
+	Bytes      []byte         `maxminddb:"bytes"`
+	Double     float64        `maxminddb:"double"`
+	Float      float32        `maxminddb:"float"`
+	Int32      int32          `maxminddb:"int32"`
+	Map        map[string]any `maxminddb:"map"`
+	Uint16     uint16         `maxminddb:"uint16"`
+	Uint32     uint32         `maxminddb:"uint32"`
+	Uint64     uint64         `maxminddb:"uint64"`
+	Uint128    big.Int        `maxminddb:"uint128"`
+	Utf8String string         `maxminddb:"utf8_string"`
+}
+
+func TestDecoder(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	verify := func(result TestType) {
+		assert.Equal(t, []uint{uint(1), uint(2), uint(3)}, result.Array)
+		assert.True(t, result.Boolean)
+		assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x2a}, result.Bytes)
+		assert.InEpsilon(t, 42.123456, result.Double, 1e-10)
+		assert.InEpsilon(t, float32(1.1), result.Float, 1e-5)
+		assert.Equal(t, int32(-268435456), result.Int32)
+
+		assert.Equal(t,
+			map[string]any{
+				"mapX": map[string]any{
+					"arrayX":       []any{uint64(7), uint64(8), uint64(9)},
+					"utf8_stringX": "hello",
+				},
+			},
+			result.Map,
+		)
+
+		assert.Equal(t, uint16(100), result.Uint16)
+		assert.Equal(t, uint32(268435456), result.Uint32)
+		assert.Equal(t, uint64(1152921504606846976), result.Uint64)
+		assert.Equal(t, "unicode! ☯ - ♫", result.Utf8String)
+		bigInt := new(big.Int)
+		bigInt.SetString("1329227995784915872903807060280344576", 10)
+		assert.Equal(t, bigInt, &result.Uint128)
+	}
+
+	{
+		// Directly lookup and decode.
+		var testV TestType
+		require.NoError(t, reader.Lookup(netip.MustParseAddr("::1.1.1.0")).Decode(&testV))
+		verify(testV)
+	}
+	{
+		// Lookup record offset, then Decode.
+		var testV TestType
+		result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
+		require.NoError(t, result.Err())
+		require.True(t, result.Found())
+
+		res := reader.LookupOffset(result.Offset())
+		require.NoError(t, res.Decode(&testV))
+		verify(testV)
+	}
+
+	require.NoError(t, reader.Close())
+}
+
+func TestDecodingWithCollectErrors(t *testing.T) {
+	type record struct {
+		Boolean string `maxminddb:"boolean"`
+		Map     struct {
+			MapX struct {
+				UTF8StringX int `maxminddb:"utf8_stringX"`
+			} `maxminddb:"mapX"`
+		} `maxminddb:"map"`
+		Uint16     uint16 `maxminddb:"uint16"`
+		Utf8String int    `maxminddb:"utf8_string"`
+	}
+
+	ip := netip.MustParseAddr("::1.1.1.0")
+
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var failFast record
+	err = reader.Lookup(ip).Decode(&failFast)
+	require.Error(t, err)
+	assert.NotImplements(t, (*interface{ Unwrap() []error })(nil), err)
+
+	collecting, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"), WithCollectErrors)
+	require.NoError(t, err)
+	defer collecting.Close()
+
+	var result record
+	err = collecting.Lookup(ip).Decode(&result)
+	require.Error(t, err)
+
+	var typeErr UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+
+	assert.ErrorContains(t, err, "decoding value for boolean: maxminddb: cannot unmarshal true (bool) into type string")
+	assert.ErrorContains(t, err, "decoding value for utf8_string: maxminddb: cannot unmarshal")
+	assert.ErrorContains(t, err, "decoding value for map: decoding value for mapX: decoding value for utf8_stringX:")
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 3)
+
+	assert.Equal(t, uint16(100), result.Uint16)
+	assert.Empty(t, result.Boolean)
+	assert.Zero(t, result.Utf8String)
+}
+
+func TestDecodingWithCollectErrorsKeepsNestedFields(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"), WithCollectErrors)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var result struct {
+		Map struct {
+			MapX struct {
+				ArrayX      []int `maxminddb:"arrayX"`
+				UTF8StringX int   `maxminddb:"utf8_stringX"`
+			} `maxminddb:"mapX"`
+		} `maxminddb:"map"`
+	}
+	err = reader.Lookup(netip.MustParseAddr("::1.1.1.0")).Decode(&result)
+	require.ErrorContains(t, err, "decoding value for map: decoding value for mapX: decoding value for utf8_stringX:")
+
+	assert.Equal(t, []int{7, 8, 9}, result.Map.MapX.ArrayX)
+	assert.Zero(t, result.Map.MapX.UTF8StringX)
+}
+
+func TestDecodePath(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+
+	result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
+	require.NoError(t, result.Err())
+
+	var u16 uint16
+
+	require.NoError(t, result.DecodePath(&u16, "uint16"))
+
+	assert.Equal(t, uint16(100), u16)
+
+	var u uint
+	require.NoError(t, result.DecodePath(&u, "array", 0))
+	assert.Equal(t, uint(1), u)
+
+	var u2 uint
+	require.NoError(t, result.DecodePath(&u2, "array", 2))
+	assert.Equal(t, uint(3), u2)
+
+	// This is past the end of the array
+	var u3 uint
+	require.NoError(t, result.DecodePath(&u3, "array", 3))
+	assert.Equal(t, uint(0), u3)
+
+	// Negative offsets
+
+	var n1 uint
+	require.NoError(t, result.DecodePath(&n1, "array", -1))
+	assert.Equal(t, uint(3), n1)
+
+	var n2 uint
+	require.NoError(t, result.DecodePath(&n2, "array", -3))
+	assert.Equal(t, uint(1), n2)
+
+	var u4 uint
+	require.NoError(t, result.DecodePath(&u4, "map", "mapX", "arrayX", 1))
+	assert.Equal(t, uint(8), u4)
+
+	// Does key not exist
+	var ne uint
+	require.NoError(t, result.DecodePath(&ne, "does-not-exist", 1))
+	assert.Equal(t, uint(0), ne)
+}
+
+func TestDecodePathLast(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
+	require.NoError(t, result.Err())
+
+	var u uint
+	require.NoError(t, result.DecodePath(&u, "array", Last))
+	assert.Equal(t, uint(3), u)
+
+	require.NoError(t, result.DecodePath(&u, "map", "mapX", "arrayX", Last))
+	assert.Equal(t, uint(9), u)
+
+	require.Error(t, result.DecodePath(&u, "map", Last))
+
+	// The array in this record is empty.
+	zeros := reader.Lookup(netip.MustParseAddr("::0.0.0.0"))
+	var empty uint
+	require.NoError(t, zeros.DecodePath(&empty, "array", Last))
+	assert.Equal(t, uint(0), empty)
+	found, err := zeros.DecodePathExists(&empty, "array", Last)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	city, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer city.Close()
+
+	var isoCode string
+	require.NoError(
+		t,
+		city.Lookup(netip.MustParseAddr("2.125.160.216")).
+			DecodePath(&isoCode, "subdivisions", Last, "iso_code"),
+	)
+	assert.Equal(t, "WBK", isoCode)
+}
+
+func TestDecodePathExists(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	// The record for ::0.0.0.0 has zero values.
+	zeros := reader.Lookup(netip.MustParseAddr("::0.0.0.0"))
+	require.NoError(t, zeros.Err())
+	result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
+	require.NoError(t, result.Err())
+
+	for _, test := range []struct {
+		result   Result
+		path     []any
+		exists   bool
+		expected uint64
+	}{
+		{result: result, path: []any{"uint16"}, exists: true, expected: 100},
+		{result: zeros, path: []any{"uint16"}, exists: true, expected: 0},
+		{result: result, path: []any{"array", 2}, exists: true, expected: 3},
+		{result: result, path: []any{"array", -3}, exists: true, expected: 1},
+		{result: result, path: []any{"array", 3}, exists: false},
+		{result: result, path: []any{"array", -4}, exists: false},
+		{result: result, path: []any{"map", "mapX", "arrayX", 1}, exists: true, expected: 8},
+		{result: result, path: []any{"does-not-exist", 1}, exists: false},
+		{result: result, path: []any{"map", "does-not-exist"}, exists: false},
+		{
+			result: reader.Lookup(netip.MustParseAddr("ffff::1")),
+			path:   []any{"uint16"},
+			exists: false,
+		},
+	} {
+		t.Run(fmt.Sprint(test.path...), func(t *testing.T) {
+			v := uint64(42)
+			exists, err := test.result.DecodePathExists(&v, test.path...)
+			require.NoError(t, err)
+			assert.Equal(t, test.exists, exists)
+			if test.exists {
+				assert.Equal(t, test.expected, v)
+			} else {
+				assert.Equal(t, uint64(42), v)
+			}
+		})
+	}
+
+	var s string
+	_, err = result.DecodePathExists(&s, "uint16")
+	require.Error(t, err)
+	_, err = result.DecodePathExists(s, "uint16")
+	require.EqualError(t, err, "result param must be a pointer")
+}
+
+func TestRecordBytes(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-decoder.mmdb",
+		"MaxMind-DB-test-nested.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			for result := range reader.Networks() {
+				require.NoError(t, result.Err())
+
+				var expected any
+				require.NoError(t, result.Decode(&expected))
+
+				raw, err := result.RecordBytes()
+				require.NoError(t, err)
+
+				var actual any
+				d := decoder{buffer: raw}
+				newOffset, err := d.decode(0, reflect.ValueOf(&actual), 0)
+				require.NoError(t, err)
+				assert.Equal(t, uint(len(raw)), newOffset)
+				assert.Equal(t, expected, actual, result.Prefix())
+			}
+		})
+	}
+
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+	raw, err := reader.Lookup(netip.MustParseAddr("1.1.1.1")).RecordBytes()
+	require.NoError(t, err)
+	assert.Nil(t, raw)
+}
+
+func TestDecodingRawFields(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+		Raw map[string][]byte `maxminddb:",raw"`
+	}
+	result := reader.Lookup(netip.MustParseAddr("81.2.69.142"))
+	require.NoError(t, result.Decode(&record))
+	assert.Equal(t, "GB", record.Country.ISOCode)
+
+	keys, err := result.TopLevelKeys()
+	require.NoError(t, err)
+	require.Len(t, record.Raw, len(keys))
+	for _, key := range keys {
+		var expected any
+		require.NoError(t, result.DecodePath(&expected, key))
+
+		var actual any
+		d := decoder{buffer: record.Raw[key]}
+		_, err := d.decode(0, reflect.ValueOf(&actual), 0)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, key)
+	}
+
+	var invalid struct {
+		Raw map[string]string `maxminddb:",raw"`
+	}
+	require.ErrorContains(t, result.Decode(&invalid), "raw requires a map[string][]byte")
+
+	var named struct {
+		Raw map[string][]byte `maxminddb:"city,raw"`
+	}
+	require.ErrorContains(t, result.Decode(&named), `raw cannot be used with the key "city"`)
+}
+
+func TestTopLevelKeys(t *testing.T) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	keys, err := db.Lookup(netip.MustParseAddr("81.2.69.142")).TopLevelKeys()
+	require.NoError(t, err)
+	assert.ElementsMatch(
+		t,
+		[]string{
+			"city",
+			"continent",
+			"country",
+			"location",
+			"registered_country",
+			"subdivisions",
+		},
+		keys,
+	)
+
+	keys, err = db.Lookup(netip.MustParseAddr("10.0.0.1")).TopLevelKeys()
+	require.NoError(t, err)
+	assert.Nil(t, keys)
+
+	noIPv4, err := Open(testFile("MaxMind-DB-no-ipv4-search-tree.mmdb"))
+	require.NoError(t, err)
+	defer noIPv4.Close()
+
+	// The records in this database are strings.
+	_, err = noIPv4.Lookup(netip.MustParseAddr("::1")).TopLevelKeys()
+	require.Error(t, err)
+}
+
+func TestDecodeAndValidate(t *testing.T) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	type location struct {
+		Location struct {
+			Latitude float64 `maxminddb:"latitude"`
+		} `maxminddb:"location"`
+	}
+	validLatitude := func(v any) error {
+		lat := v.(*location).Location.Latitude
+		if lat < -90 || lat > 90 {
+			return fmt.Errorf("latitude %v out of range", lat)
+		}
+		return nil
+	}
+
+	var record location
+	result := db.Lookup(netip.MustParseAddr("81.2.69.142"))
+	require.NoError(t, result.DecodeAndValidate(&record, validLatitude))
+	assert.InEpsilon(t, 51.5142, record.Location.Latitude, 1e-10)
+
+	errInvalid := errors.New("invalid")
+	var called []int
+	err = result.DecodeAndValidate(
+		&record,
+		func(any) error { called = append(called, 1); return nil },
+		func(any) error { called = append(called, 2); return errInvalid },
+		func(any) error { called = append(called, 3); return nil },
+	)
+	require.ErrorIs(t, err, errInvalid)
+	assert.Equal(t, []int{1, 2}, called)
+
+	notFound := db.Lookup(netip.MustParseAddr("1.1.1.1"))
+	require.NoError(t, notFound.DecodeAndValidate(&record, func(any) error { return errInvalid }))
+}
+
+func TestDecodeStringMapInto(t *testing.T) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer db.Close()
 
-	var recordInterface any
-	err = reader.Lookup(netip.MustParseAddr("::1.1.1.0")).Decode(&recordInterface)
-	require.NoError(t, err, "unexpected error while doing lookup: %v", err)
+	names := map[string]string{"stale": "value"}
+	result := db.Lookup(netip.MustParseAddr("81.2.69.142"))
+	require.NoError(t, result.DecodeStringMapInto(names, "country", "names"))
+	assert.Equal(t, "United Kingdom", names["en"])
+	assert.NotContains(t, names, "stale")
 
-	checkDecodingToInterface(t, recordInterface)
-}
+	require.NoError(t, result.DecodeStringMapInto(names, "does-not-exist"))
+	assert.Empty(t, names)
 
-func TestMetadataPointer(t *testing.T) {
-	_, err := Open(testFile("MaxMind-DB-test-metadata-pointers.mmdb"))
-	require.NoError(t, err, "unexpected error while opening database: %v", err)
+	assert.Error(t, result.DecodeStringMapInto(nil, "country", "names"))
 }
 
-func checkDecodingToInterface(t *testing.T, recordInterface any) {
-	record := recordInterface.(map[string]any)
-	assert.Equal(t, []any{uint64(1), uint64(2), uint64(3)}, record["array"])
-	assert.Equal(t, true, record["boolean"])
-	assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x2a}, record["bytes"])
-	assert.InEpsilon(t, 42.123456, record["double"], 1e-10)
-	assert.InEpsilon(t, float32(1.1), record["float"], 1e-5)
-	assert.Equal(t, -268435456, record["int32"])
-	assert.Equal(t,
-		map[string]any{
-			"mapX": map[string]any{
-				"arrayX":       []any{uint64(7), uint64(8), uint64(9)},
-				"utf8_stringX": "hello",
-			},
-		},
-		record["map"],
-	)
+func BenchmarkNamesDecodeFreshMap(b *testing.B) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(b, err)
 
-	assert.Equal(t, uint64(100), record["uint16"])
-	assert.Equal(t, uint64(268435456), record["uint32"])
-	assert.Equal(t, uint64(1152921504606846976), record["uint64"])
-	assert.Equal(t, "unicode! ☯ - ♫", record["utf8_string"])
-	bigInt := new(big.Int)
-	bigInt.SetString("1329227995784915872903807060280344576", 10)
-	assert.Equal(t, bigInt, record["uint128"])
+	for i := 0; i < b.N; i++ {
+		for r := range db.Networks() {
+			var names map[string]string
+			if err := r.DecodePath(&names, "country", "names"); err != nil {
+				b.Error(err)
+			}
+		}
+	}
+	require.NoError(b, db.Close(), "error on close")
 }
 
-type TestType struct {
-	Array      []uint         `maxminddb:"array"`
-	Boolean    bool           `maxminddb:"boolean"`
-	Bytes      []byte         `maxminddb:"bytes"`
-	Double     float64        `maxminddb:"double"`
-	Float      float32        `maxminddb:"float"`
-	Int32      int32          `maxminddb:"int32"`
-	Map        map[string]any `maxminddb:"map"`
-	Uint16     uint16         `maxminddb:"uint16"`
-	Uint32     uint32         `maxminddb:"uint32"`
-	Uint64     uint64         `maxminddb:"uint64"`
-	Uint128    big.Int        `maxminddb:"uint128"`
-	Utf8String string         `maxminddb:"utf8_string"`
+func BenchmarkNamesDecodeReusedMap(b *testing.B) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(b, err)
+
+	names := map[string]string{}
+	for i := 0; i < b.N; i++ {
+		for r := range db.Networks() {
+			if err := r.DecodeStringMapInto(names, "country", "names"); err != nil {
+				b.Error(err)
+			}
+		}
+	}
+	require.NoError(b, db.Close(), "error on close")
 }
 
-func TestDecoder(t *testing.T) {
-	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+func TestDecodeMapValues(t *testing.T) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
 	require.NoError(t, err)
+	defer db.Close()
 
-	verify := func(result TestType) {
-		assert.Equal(t, []uint{uint(1), uint(2), uint(3)}, result.Array)
-		assert.True(t, result.Boolean)
-		assert.Equal(t, []byte{0x00, 0x00, 0x00, 0x2a}, result.Bytes)
-		assert.InEpsilon(t, 42.123456, result.Double, 1e-10)
-		assert.InEpsilon(t, float32(1.1), result.Float, 1e-5)
-		assert.Equal(t, int32(-268435456), result.Int32)
+	result := db.Lookup(netip.MustParseAddr("81.2.69.142"))
 
-		assert.Equal(t,
-			map[string]any{
-				"mapX": map[string]any{
-					"arrayX":       []any{uint64(7), uint64(8), uint64(9)},
-					"utf8_stringX": "hello",
-				},
-			},
-			result.Map,
-		)
+	var expected map[string]string
+	require.NoError(t, result.DecodePath(&expected, "country", "names"))
 
-		assert.Equal(t, uint16(100), result.Uint16)
-		assert.Equal(t, uint32(268435456), result.Uint32)
-		assert.Equal(t, uint64(1152921504606846976), result.Uint64)
-		assert.Equal(t, "unicode! ☯ - ♫", result.Utf8String)
-		bigInt := new(big.Int)
-		bigInt.SetString("1329227995784915872903807060280344576", 10)
-		assert.Equal(t, bigInt, &result.Uint128)
+	names, err := DecodeMapValues[string](result, "country", "names")
+	require.NoError(t, err)
+	actual := map[string]string{}
+	for lang, name := range names {
+		actual[lang] = name
 	}
-
-	{
-		// Directly lookup and decode.
-		var testV TestType
-		require.NoError(t, reader.Lookup(netip.MustParseAddr("::1.1.1.0")).Decode(&testV))
-		verify(testV)
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, "United Kingdom", actual["en"])
+
+	// Stopping early
+	count := 0
+	for range names {
+		count++
+		break
 	}
-	{
-		// Lookup record offset, then Decode.
-		var testV TestType
-		result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
-		require.NoError(t, result.Err())
-		require.True(t, result.Found())
+	assert.Equal(t, 1, count)
 
-		res := reader.LookupOffset(result.Offset())
-		require.NoError(t, res.Decode(&testV))
-		verify(testV)
+	// A value that cannot be decoded stops the iteration.
+	values, err := DecodeMapValues[uint](result, "country")
+	require.NoError(t, err)
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
 	}
+	assert.NotEmpty(t, keys)
+	assert.Less(t, len(keys), 3)
 
-	require.NoError(t, reader.Close())
-}
+	_, err = DecodeMapValues[string](result, "country", "iso_code")
+	require.Error(t, err)
 
-func TestDecodePath(t *testing.T) {
-	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
+	missing, err := DecodeMapValues[string](result, "missing")
 	require.NoError(t, err)
+	for range missing {
+		t.Fatal("unexpected value")
+	}
 
-	result := reader.Lookup(netip.MustParseAddr("::1.1.1.0"))
-	require.NoError(t, result.Err())
+	// {"a": 1, 2: 3}, where the second key is not a string.
+	reader := twoNodeDatabase(t, "000012", "000012", "e2"+"4161"+"a101"+"a102"+"a103")
+	values, err = DecodeMapValues[uint](reader.Lookup(netip.MustParseAddr("1.1.1.1")))
+	require.NoError(t, err)
+	var entries []string
+	for key, value := range values {
+		entries = append(entries, fmt.Sprintf("%q=%d", key, value))
+	}
+	assert.Equal(t, []string{`"a"=1`, `""=0`}, entries)
+}
 
-	var u16 uint16
+func TestDecodeSliceInto(t *testing.T) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer db.Close()
 
-	require.NoError(t, result.DecodePath(&u16, "uint16"))
+	type subdivision struct {
+		ISOCode string `maxminddb:"iso_code"`
+	}
+	dst := make([]subdivision, 0, 4)
+	dst = append(dst, subdivision{ISOCode: "stale"})
 
-	assert.Equal(t, uint16(100), u16)
+	result := db.Lookup(netip.MustParseAddr("81.2.69.142"))
+	subdivisions, err := DecodeSliceInto(result, dst, "subdivisions")
+	require.NoError(t, err)
+	assert.Equal(t, []subdivision{{ISOCode: "ENG"}}, subdivisions)
+	assert.Same(t, &dst[:1][0], &subdivisions[0], "backing array is reused")
 
-	var u uint
-	require.NoError(t, result.DecodePath(&u, "array", 0))
-	assert.Equal(t, uint(1), u)
+	subdivisions, err = DecodeSliceInto(result, subdivisions, "missing")
+	require.NoError(t, err)
+	assert.Empty(t, subdivisions)
 
-	var u2 uint
-	require.NoError(t, result.DecodePath(&u2, "array", 2))
-	assert.Equal(t, uint(3), u2)
+	_, err = DecodeSliceInto(result, subdivisions, "country")
+	require.Error(t, err)
+}
 
-	// This is past the end of the array
-	var u3 uint
-	require.NoError(t, result.DecodePath(&u3, "array", 3))
-	assert.Equal(t, uint(0), u3)
+func TestDecodeSliceIntoWithHugeSize(t *testing.T) {
+	// {"a": an array claiming 65821 + 0xffffff elements followed by a
+	// single element}
+	reader := twoNodeDatabase(t, "000012", "000012", "e14161"+"1f04ffffff"+"a101")
+	result := reader.Lookup(netip.MustParseAddr("1.1.1.1"))
 
-	// Negative offsets
+	values, err := DecodeSliceInto[uint16](result, nil, "a")
+	require.ErrorContains(t, err, "unexpected end of database")
+	assert.Empty(t, values)
+	assert.Less(t, cap(values), 1024, "preallocation is capped by the buffer size")
+}
 
-	var n1 uint
-	require.NoError(t, result.DecodePath(&n1, "array", -1))
-	assert.Equal(t, uint(3), n1)
+func BenchmarkSubdivisionsDecodeFreshSlice(b *testing.B) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(b, err)
 
-	var n2 uint
-	require.NoError(t, result.DecodePath(&n2, "array", -3))
-	assert.Equal(t, uint(1), n2)
+	type subdivision struct {
+		ISOCode string `maxminddb:"iso_code"`
+	}
+	for i := 0; i < b.N; i++ {
+		for r := range db.Networks() {
+			var subdivisions []subdivision
+			if err := r.DecodePath(&subdivisions, "subdivisions"); err != nil {
+				b.Error(err)
+			}
+		}
+	}
+	require.NoError(b, db.Close(), "error on close")
+}
 
-	var u4 uint
-	require.NoError(t, result.DecodePath(&u4, "map", "mapX", "arrayX", 1))
-	assert.Equal(t, uint(8), u4)
+func BenchmarkSubdivisionsDecodeReusedSlice(b *testing.B) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(b, err)
 
-	// Does key not exist
-	var ne uint
-	require.NoError(t, result.DecodePath(&ne, "does-not-exist", 1))
-	assert.Equal(t, uint(0), ne)
+	type subdivision struct {
+		ISOCode string `maxminddb:"iso_code"`
+	}
+	var subdivisions []subdivision
+	for i := 0; i < b.N; i++ {
+		for r := range db.Networks() {
+			subdivisions, err = DecodeSliceInto(r, subdivisions, "subdivisions")
+			if err != nil {
+				b.Error(err)
+			}
+		}
+	}
+	require.NoError(b, db.Close(), "error on close")
 }
 
 type TestInterface interface {
@@ -610,6 +2306,153 @@ func TestNestedOffsetDecode(t *testing.T) {
 	require.NoError(t, db.Close())
 }
 
+func TestDecodingOffsetTag(t *testing.T) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	result := db.Lookup(netip.MustParseAddr("81.2.69.142"))
+	require.NoError(t, result.Err())
+
+	var record struct {
+		CountryOffset uintptr `maxminddb:"country,offset"`
+		Location      struct {
+			TimeZoneOffset uintptr `maxminddb:"time_zone,offset"`
+		} `maxminddb:"location"`
+	}
+	require.NoError(t, result.Decode(&record))
+	require.NotZero(t, record.CountryOffset)
+
+	var country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	}
+	require.NoError(t, db.LookupOffset(record.CountryOffset).Decode(&country))
+	assert.Equal(t, "GB", country.IsoCode)
+
+	var timeZone string
+	require.NoError(t, db.LookupOffset(record.Location.TimeZoneOffset).Decode(&timeZone))
+	assert.Equal(t, "Europe/London", timeZone)
+
+	var invalid struct {
+		Country string `maxminddb:"country,offset"`
+	}
+	require.ErrorContains(t, result.Decode(&invalid), "offset requires a uintptr")
+}
+
+func TestDecodingAddrTag(t *testing.T) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	type record struct {
+		Addr    netip.Addr `maxminddb:",addr"`
+		Country struct {
+			IsoCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+
+	ip := netip.MustParseAddr("81.2.69.142")
+	result := db.Lookup(ip)
+	require.NoError(t, result.Err())
+
+	var r record
+	require.NoError(t, result.Decode(&r))
+	assert.Equal(t, ip, r.Addr)
+	assert.Equal(t, "GB", r.Country.IsoCode)
+
+	r = record{}
+	found, err := result.DecodePathExists(&r)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, ip, r.Addr)
+
+	for result := range db.Networks() {
+		var r record
+		require.NoError(t, result.Decode(&r))
+		assert.Equal(t, result.Prefix().Addr(), r.Addr)
+	}
+
+	var invalid struct {
+		Addr string `maxminddb:",addr"`
+	}
+	require.ErrorContains(t, result.Decode(&invalid), "addr requires a netip.Addr")
+
+	var named struct {
+		Addr netip.Addr `maxminddb:"ip,addr"`
+	}
+	require.ErrorContains(t, result.Decode(&named), `addr cannot be used with the key "ip"`)
+}
+
+func TestDecodingStringToNetip(t *testing.T) {
+	db, err := Open(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	var record struct {
+		IP netip.Addr `maxminddb:"ip"`
+	}
+	require.NoError(t, db.Lookup(netip.MustParseAddr("1.1.1.3")).Decode(&record))
+	assert.Equal(t, netip.MustParseAddr("1.1.1.2"), record.IP)
+
+	var ip netip.Addr
+	require.NoError(t, db.Lookup(netip.MustParseAddr("1.1.1.3")).DecodePath(&ip, "ip"))
+	assert.Equal(t, netip.MustParseAddr("1.1.1.2"), ip)
+
+	for _, test := range []struct {
+		value    string
+		expected any
+		err      string
+	}{
+		{value: "1.0.0.0/24", expected: netip.MustParsePrefix("1.0.0.0/24")},
+		{value: "2001:db8::/32", expected: netip.MustParsePrefix("2001:db8::/32")},
+		{value: "1.1.1.1", expected: netip.MustParseAddr("1.1.1.1")},
+		{value: "::1", expected: netip.MustParseAddr("::1")},
+		{value: "", expected: netip.Addr{}, err: "cannot unmarshal  (string) into type netip.Addr"},
+		{value: "1.1.1.1/24", expected: netip.Addr{}, err: "into type netip.Addr"},
+		{value: "1.1.1.1", expected: netip.Prefix{}, err: "into type netip.Prefix"},
+		{value: "not a prefix", expected: netip.Prefix{}, err: "into type netip.Prefix"},
+	} {
+		t.Run(fmt.Sprintf("%T %q", test.expected, test.value), func(t *testing.T) {
+			buf := append([]byte{0x40 | byte(len(test.value))}, test.value...)
+			d := decoder{buffer: buf}
+			result := reflect.New(reflect.TypeOf(test.expected))
+			_, err := d.decode(0, result, 0)
+			if test.err != "" {
+				var typeErr UnmarshalTypeError
+				require.ErrorAs(t, err, &typeErr)
+				require.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, result.Elem().Interface())
+		})
+	}
+}
+
+func TestDecodingScaledFloat(t *testing.T) {
+	db, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	var record struct {
+		Location struct {
+			Latitude  int64 `maxminddb:"latitude,scale=10000000"`
+			Longitude int32 `maxminddb:"longitude,scale=10000"`
+		} `maxminddb:"location"`
+	}
+	require.NoError(t, db.Lookup(netip.MustParseAddr("81.2.69.142")).Decode(&record))
+	assert.Equal(t, int64(515142000), record.Location.Latitude)
+	assert.Equal(t, int32(-931), record.Location.Longitude)
+
+	var invalid struct {
+		Location struct {
+			Latitude int64 `maxminddb:"latitude,scale=abc"`
+		} `maxminddb:"location"`
+	}
+	err = db.Lookup(netip.MustParseAddr("81.2.69.142")).Decode(&invalid)
+	assert.ErrorContains(t, err, `invalid scale "abc"`)
+}
+
 func TestDecodingUint16IntoInt(t *testing.T) {
 	reader, err := Open(testFile("MaxMind-DB-test-decoder.mmdb"))
 	require.NoError(t, err, "unexpected error while opening database: %v", err)
@@ -661,6 +2504,41 @@ func TestInvalidNodeCountDatabase(t *testing.T) {
 	assert.Equal(t, expected, err)
 }
 
+func TestMetadataMarkerInData(t *testing.T) {
+	original, err := os.ReadFile(testFile("MaxMind-DB-test-decoder.mmdb"))
+	require.NoError(t, err)
+	markerStart := bytes.LastIndex(original, metadataStartMarker)
+	require.NotEqual(t, -1, markerStart)
+
+	// A string containing the marker bytes at the end of the data section,
+	// before the real metadata.
+	markerString := append([]byte{0x40 | byte(len(metadataStartMarker))}, metadataStartMarker...)
+	inData := slices.Concat(original[:markerStart], markerString, original[markerStart:])
+
+	// The marker bytes after the real metadata.
+	trailing := slices.Concat(original, metadataStartMarker)
+
+	expected, err := FromBytes(original)
+	require.NoError(t, err)
+
+	for name, buffer := range map[string][]byte{
+		"in data section": inData,
+		"trailing":        trailing,
+	} {
+		t.Run(name, func(t *testing.T) {
+			reader, err := FromBytes(buffer)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Metadata, reader.Metadata)
+
+			var record, expectedRecord any
+			ip := netip.MustParseAddr("::1.1.1.0")
+			require.NoError(t, reader.Lookup(ip).Decode(&record))
+			require.NoError(t, expected.Lookup(ip).Decode(&expectedRecord))
+			assert.Equal(t, expectedRecord, record)
+		})
+	}
+}
+
 func TestMissingDatabase(t *testing.T) {
 	reader, err := Open("file-does-not-exist.mmdb")
 	assert.Nil(t, reader, "received reader when doing lookups on DB that doesn't exist")
@@ -870,6 +2748,29 @@ func BenchmarkLookupNetwork(b *testing.B) {
 	require.NoError(b, db.Close(), "error on close")
 }
 
+func BenchmarkLookupBatch(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+
+	//nolint:gosec // this is a test
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	ips := make([]netip.Addr, 1000)
+	s := make(net.IP, 4)
+	for i := range ips {
+		ips[i] = randomIPv4Address(r, s)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i += len(ips) {
+		for _, res := range db.LookupBatch(ips) {
+			if err := res.Err(); err != nil {
+				b.Error(err)
+			}
+		}
+	}
+	require.NoError(b, db.Close(), "error on close")
+}
+
 type fullCity struct {
 	City struct {
 		GeoNameID uint              `maxminddb:"geoname_id"`
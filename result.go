@@ -2,9 +2,13 @@ package maxminddb
 
 import (
 	"errors"
+	"fmt"
+	"iter"
 	"math"
 	"net/netip"
 	"reflect"
+	"slices"
+	"strconv"
 )
 
 const notFound uint = math.MaxUint
@@ -15,6 +19,9 @@ type Result struct {
 	decoder   decoder
 	offset    uint
 	prefixLen uint8
+	// normalizeIPv4Prefix is set when the Reader was opened with
+	// WithIPv4PrefixNormalization.
+	normalizeIPv4Prefix bool
 }
 
 // Decode unmarshals the data from the data section into the value pointed to
@@ -28,7 +35,69 @@ type Result struct {
 //
 // If the Reader.Lookup call did not find a value for the IP address, no error
 // will be returned and v will be unchanged.
+//
+// When decoding into a struct, the maxminddb struct tag sets the map key for
+// a field, e.g., `maxminddb:"iso_code"`. Untagged fields use the field name,
+// or the lowercased field name with WithUntaggedLowercase, and fields tagged
+// with "-" are ignored. The key may be followed by
+// comma-separated options:
+//
+//   - scale=N: multiply a floating point value by N before storing it. This
+//     allows values such as coordinates to be stored in integer fields. The
+//     scaled value is rounded to the nearest integer, e.g.,
+//     `maxminddb:"latitude,scale=10000000"`.
+//   - string: parse a number stored as a string, e.g., "15169", into an
+//     integer or float field, e.g., `maxminddb:"asn,string"`. Values that
+//     are not strings are decoded as usual.
+//   - raw: on a field of type map[string][]byte with no key, e.g.,
+//     `maxminddb:",raw"`, store the encoded value for every key of the map,
+//     in addition to decoding the other fields as usual. Pointers within the
+//     values are replaced with the data they point to, as with RecordBytes.
+//   - addr: on a field of type netip.Addr with no key, e.g.,
+//     `maxminddb:",addr"`, store the IP address of the Result, i.e., the
+//     address passed to Lookup or the first address of the network yielded
+//     by Networks. This is only set by Decode, DecodePath, and
+//     DecodePathExists.
+//   - offset: on a field of type uintptr, store the offset of the value in
+//     the data section rather than decoding it, e.g.,
+//     `maxminddb:"country,offset"`. If the value is stored through a pointer,
+//     the offset it points to is stored. The offset may be passed to
+//     Reader.LookupOffset to decode the value later.
+//
+// Bytes may be decoded into a type that implements
+// encoding.BinaryUnmarshaler, such as a wrapper around a protocol buffer
+// message stored in its serialized form. Likewise, strings may be decoded
+// into a type that implements encoding.TextUnmarshaler.
+//
+// Strings may be decoded into a []byte, in which case the raw bytes of the
+// string are stored.
+//
+// Strings may be decoded into a netip.Addr or netip.Prefix, in which case they
+// are parsed with netip.ParseAddr or netip.ParsePrefix. A string that cannot
+// be parsed results in an UnmarshalTypeError.
+//
+// The omitempty option is accepted and ignored so that tag text may be shared
+// with encoding/json. Other unknown options are also ignored, but they are
+// reported to the logger set with WithLogger.
+//
+// Unsigned integers may be decoded into a time.Time, in which case they are
+// treated as seconds since the Unix epoch. This is intended for custom
+// databases that store timestamps this way. A uint128 may be decoded into a
+// big.Int, a [16]byte, which holds its big-endian bytes, or a string, which
+// holds its decimal representation.
+//
+// An array may be decoded into a struct whose fields are tagged with array
+// indexes rather than keys, e.g., `maxminddb:"[0]"`. This is useful for
+// records where the position of a value implies its meaning. Elements without
+// a corresponding field are skipped.
 func (r Result) Decode(v any) error {
+	return r.decode(v, nil)
+}
+
+// decode decodes the record into v, skipping the values of the record's
+// top-level keys in skipKeys if the record is a map.
+func (r Result) decode(v any, skipKeys []string) (err error) {
+	defer r.recoverPanic(&err)
 	if r.err != nil {
 		return r.err
 	}
@@ -39,16 +108,85 @@ func (r Result) Decode(v any) error {
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return errors.New("result param must be a pointer")
 	}
-
+	if err := r.checkEmpty(); err != nil {
+		return err
+	}
 	if dser, ok := v.(deserializer); ok {
 		_, err := r.decoder.decodeToDeserializer(r.offset, dser, 0, false)
 		return err
 	}
 
-	_, err := r.decoder.decode(r.offset, rv, 0)
+	state := &decodeState{addr: r.ip}
+	if len(skipKeys) > 0 {
+		typeNum, _, _, err := r.decoder.decodeCtrlDataAndFollow(r.offset)
+		if err != nil {
+			return err
+		}
+		if typeNum == _Map {
+			state.skipKeys = skipKeys
+		}
+	}
+	_, err = r.decoder.decodeValue(r.offset, rv, 0, state)
 	return err
 }
 
+// DecodeWithoutPointerFollowing decodes the record into v, as with Decode,
+// except that decoding stops at pointers in the data section, as with the
+// WithoutPointerFollowing ReaderOption. Rather than decoding the value that a
+// pointer refers to, the offset of that value is stored in a uintptr or an
+// empty interface. Other decodes from the Reader are not affected.
+//
+// This exposes the internal layout of the data section and is intended for
+// diagnostic tooling only.
+func (r Result) DecodeWithoutPointerFollowing(v any) error {
+	r.decoder.opts.disablePointerFollowing = true
+	return r.Decode(v)
+}
+
+// DecodeExcept decodes the record into v, as with Decode, except that the
+// values of the record's top-level keys in skipKeys are skipped without
+// being decoded, even if v has fields for them. Those fields are left
+// unchanged. This saves the cost of decoding large values that are not
+// needed, e.g., the names of a City record's city.
+//
+// skipKeys only applies to records that are maps. It is not applied when v
+// implements the experimental deserializer interface.
+func (r Result) DecodeExcept(v any, skipKeys ...string) error {
+	return r.decode(v, skipKeys)
+}
+
+// DecodeAndValidate decodes the record into v, as with Decode, and then calls
+// each of the validators with v in order. The first error returned by a
+// validator is returned. If decoding fails or the Reader.Lookup call did not
+// find a value for the IP address, the validators are not called.
+func (r Result) DecodeAndValidate(v any, validators ...func(v any) error) error {
+	if err := r.Decode(v); err != nil {
+		return err
+	}
+	if r.offset == notFound {
+		return nil
+	}
+	for _, validate := range validators {
+		if err := validate(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LastElement is the type of Last.
+type LastElement struct{}
+
+// Last may be used as an element of a path, e.g., in DecodePath, to select
+// the last element of an array. It is equivalent to an index of -1. If the
+// array is empty, the path is not found.
+//
+// Example usage:
+//
+//	var isoCode string
+//	err := result.DecodePath(&isoCode, "subdivisions", maxminddb.Last, "iso_code")
+var Last = LastElement{}
+
 // DecodePath unmarshals a value from data section into v, following the
 // specified path.
 //
@@ -64,7 +202,7 @@ func (r Result) Decode(v any) error {
 // For maps, string path elements are used as keys.
 // For arrays, int path elements are used as indices. A negative offset will
 // return values from the end of the array, e.g., -1 will return the last
-// element.
+// element. Last may also be used to select the last element.
 //
 // If the path is empty, the entire data structure is decoded into v.
 //
@@ -82,7 +220,8 @@ func (r Result) Decode(v any) error {
 //
 //	var geonameID int
 //	err := result.DecodePath(&geonameID, "subdivisions", 0, "geoname_id")
-func (r Result) DecodePath(v any, path ...any) error {
+func (r Result) DecodePath(v any, path ...any) (err error) {
+	defer r.recoverPanic(&err)
 	if r.err != nil {
 		return r.err
 	}
@@ -93,7 +232,276 @@ func (r Result) DecodePath(v any, path ...any) error {
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return errors.New("result param must be a pointer")
 	}
-	return r.decoder.decodePath(r.offset, path, rv)
+	if err := r.checkEmpty(); err != nil {
+		return err
+	}
+	return r.decoder.decodePath(r.offset, path, rv, r.ip)
+}
+
+// DecodePathExists is the same as DecodePath, but it also reports whether the
+// path was found. It returns false, and leaves v unchanged, if the record was
+// not found, if a map key in the path does not exist, or if an array index in
+// the path is out of range. This allows a missing value to be distinguished
+// from one that is the zero value.
+func (r Result) DecodePathExists(v any, path ...any) (found bool, err error) {
+	defer r.recoverPanic(&err)
+	if r.err != nil {
+		return false, r.err
+	}
+	if r.offset == notFound {
+		return false, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return false, errors.New("result param must be a pointer")
+	}
+	if err := r.checkEmpty(); err != nil {
+		return false, err
+	}
+	offset, exists, err := r.decoder.followPath(r.offset, path)
+	if err != nil || !exists {
+		return false, err
+	}
+	state := &decodeState{addr: r.ip}
+	if _, err := r.decoder.decodeValue(offset, rv, len(path), state); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// recoverPanic converts a panic into an error stored in err if the Reader was
+// opened with WithPanicRecovery. It must be deferred.
+func (r Result) recoverPanic(err *error) {
+	if !r.decoder.opts.recoverPanics {
+		return
+	}
+	if p := recover(); p != nil {
+		*err = newInvalidDatabaseError("panic while decoding: %v", p)
+	}
+}
+
+// checkEmpty returns ErrEmptyRecord if WithReportEmpty is set and the record
+// is an empty map or array.
+func (r Result) checkEmpty() error {
+	if !r.decoder.opts.reportEmpty {
+		return nil
+	}
+	empty, err := r.decoder.peekEmpty(r.offset)
+	if err != nil {
+		return err
+	}
+	if empty {
+		return ErrEmptyRecord
+	}
+	return nil
+}
+
+// DecodeStringMapInto clears dst and then fills it with the map found by
+// following path, as with DecodePath. This allows a single map to be reused
+// across many records, e.g., when iterating over the names in each network,
+// rather than allocating a new map for every record.
+//
+// dst is cleared first even if the record or path is not found, in which case
+// it is left empty. The map values in the database must be strings.
+func (r Result) DecodeStringMapInto(dst map[string]string, path ...any) error {
+	if dst == nil {
+		return errors.New("dst map must not be nil")
+	}
+	clear(dst)
+	return r.DecodePath(&dst, path...)
+}
+
+// RecordBytes returns a copy of the encoded record in the MaxMind DB data
+// format. Pointers within the record are replaced with the values they point
+// to, so the bytes are self-contained and may be decoded or written to
+// another database without the rest of the data section. As a result, the
+// bytes may be larger than the record in the database.
+//
+// If the Reader.Lookup call did not find a value for the IP address, no error
+// and a nil slice will be returned.
+func (r Result) RecordBytes() (b []byte, err error) {
+	defer r.recoverPanic(&err)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.offset == notFound {
+		return nil, nil
+	}
+	b, _, err = r.decoder.appendInlined(nil, r.offset, 0)
+	return b, err
+}
+
+// DecodeSliceInto decodes the array found by following path, as with
+// DecodePath, into dst and returns the resulting slice. The capacity of dst
+// is reused if it is large enough, which avoids allocating a new slice for
+// every record when decoding many arrays of the same type. The existing
+// elements of dst are overwritten.
+//
+// If the record or path is not found, dst[:0] is returned.
+func DecodeSliceInto[T any](r Result, dst []T, path ...any) (values []T, err error) {
+	defer r.recoverPanic(&err)
+	dst = dst[:0]
+	if r.err != nil {
+		return dst, r.err
+	}
+	if r.offset == notFound {
+		return dst, nil
+	}
+	offset, found, err := r.decoder.followPath(r.offset, path)
+	if err != nil || !found {
+		return dst, err
+	}
+	typeNum, size, offset, err := r.decoder.decodeCtrlDataAndFollow(offset)
+	if err != nil {
+		return dst, err
+	}
+	if typeNum != _Slice {
+		return dst, fmt.Errorf("expected an array but found %d", typeNum)
+	}
+
+	dst = slices.Grow(dst[:0], r.decoder.sliceCapacity(size, offset))
+	for range size {
+		var zero T
+		dst = append(dst, zero)
+		offset, err = r.decoder.decode(offset, reflect.ValueOf(&dst[len(dst)-1]).Elem(), len(path))
+		if err != nil {
+			return dst[:0], err
+		}
+	}
+	return dst, nil
+}
+
+// DecodeMapValues returns an iterator over the map found by following path,
+// as with DecodePath. It yields each key along with its value decoded into a
+// T. The values are decoded lazily as the iteration proceeds, avoiding
+// building the whole map when only some of the values are needed.
+//
+// An error is returned if the path cannot be followed or if the value is not
+// a map. If a value cannot be decoded into a T during the iteration, its key
+// is yielded with the zero value of T and the iteration stops. Likewise, if
+// a key cannot be decoded, an empty key is yielded with the zero value of T
+// and the iteration stops. Use DecodePath to check for such errors. If the
+// record or path is not found, the iterator yields nothing.
+func DecodeMapValues[T any](
+	r Result,
+	path ...any,
+) (values iter.Seq2[string, T], err error) {
+	empty := func(func(string, T) bool) {}
+	// values must not be nil if a panic is recovered.
+	values = empty
+	defer r.recoverPanic(&err)
+	if r.err != nil {
+		return empty, r.err
+	}
+	if r.offset == notFound {
+		return empty, nil
+	}
+	offset, found, err := r.decoder.followPath(r.offset, path)
+	if err != nil || !found {
+		return empty, err
+	}
+	typeNum, size, offset, err := r.decoder.decodeCtrlDataAndFollow(offset)
+	if err != nil {
+		return empty, err
+	}
+	if typeNum != _Map {
+		return empty, fmt.Errorf("expected a map but found %d", typeNum)
+	}
+
+	// next decodes the key and value at offset. The key is nil if it could
+	// not be decoded.
+	next := func(offset uint, v *T) (key []byte, newOffset uint, err error) {
+		defer r.recoverPanic(&err)
+		key, newOffset, err = r.decoder.decodeKey(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		newOffset, err = r.decoder.decode(newOffset, reflect.ValueOf(v).Elem(), len(path))
+		return key, newOffset, err
+	}
+
+	return func(yield func(string, T) bool) {
+		offset := offset
+		for i := uint(0); i < size; i++ {
+			var v T
+			key, newOffset, err := next(offset, &v)
+			if err != nil {
+				var zero T
+				yield(string(key), zero)
+				return
+			}
+			offset = newOffset
+			if !yield(string(key), v) {
+				return
+			}
+		}
+	}, nil
+}
+
+// TopLevelKeys returns the keys of the record, which must be a map, in the
+// order that they are stored in the database. The values are skipped rather
+// than decoded, making this cheaper than decoding into a map[string]any when
+// only the set of keys is needed.
+//
+// If the Reader.Lookup call did not find a value for the IP address, no error
+// and a nil slice will be returned.
+func (r Result) TopLevelKeys() (keys []string, err error) {
+	defer r.recoverPanic(&err)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.offset == notFound {
+		return nil, nil
+	}
+	return r.decoder.decodeMapKeys(r.offset)
+}
+
+// PeekEmpty reports whether the value found by following path, as with
+// DecodePath, is a map or array with no elements. This allows callers to skip
+// decoding empty containers without iterating over them. It returns false if
+// the value is not a map or array, or if the record or path is not found.
+//
+// With no path, PeekEmpty reports whether the record itself is empty, e.g.,
+// to treat empty records returned by Lookup as absent.
+func (r Result) PeekEmpty(path ...any) (empty bool, err error) {
+	defer r.recoverPanic(&err)
+	if r.err != nil {
+		return false, r.err
+	}
+	if r.offset == notFound {
+		return false, nil
+	}
+	offset, found, err := r.decoder.followPath(r.offset, path)
+	if err != nil || !found {
+		return false, err
+	}
+	return r.decoder.peekEmpty(offset)
+}
+
+// CompareUint128 compares the uint128 value found by following path, as with
+// DecodePath, with the 128-bit value whose high and low 64 bits are hi and lo.
+// It returns -1 if the value in the database is less than hi and lo, 0 if
+// they are equal, and +1 if it is greater. Unlike decoding into a *big.Int,
+// this does not allocate, making it suitable for sorting records.
+//
+// An error is returned if the record or path is not found or if the value is
+// not a uint128.
+func (r Result) CompareUint128(hi, lo uint64, path ...any) (cmp int, err error) {
+	defer r.recoverPanic(&err)
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.offset == notFound {
+		return 0, errors.New("cannot compare a record that was not found")
+	}
+	offset, found, err := r.decoder.followPath(r.offset, path)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("path %v not found", path)
+	}
+	return r.decoder.compareUint128(offset, hi, lo)
 }
 
 // Err provides a way to check whether there was an error during the lookup
@@ -116,6 +524,10 @@ func (r Result) Found() bool {
 // data record, other data in Result  may differ between lookups. The offset
 // is only valid for the current database version. If you update the database
 // file, you must invalidate any cache associated with the previous version.
+//
+// The offset is also set on the Results yielded by Networks and
+// NetworksWithin, so networks that share a record may be grouped without
+// decoding it. See also GroupByRecord.
 func (r Result) Offset() uintptr {
 	return uintptr(r.offset)
 }
@@ -135,6 +547,9 @@ func (r Result) Prefix() netip.Prefix {
 		// with databases currently distributed by MaxMind as all of them
 		// have an IPv4 subtree that is greater than a single node.
 		if prefixLen < 96 {
+			if r.normalizeIPv4Prefix {
+				return netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+			}
 			return netip.PrefixFrom(zeroIP, prefixLen)
 		}
 		prefixLen -= 96
@@ -143,3 +558,30 @@ func (r Result) Prefix() netip.Prefix {
 	prefix, _ := ip.Prefix(prefixLen)
 	return prefix
 }
+
+// TreeDepth returns the depth in the search tree, in bits, at which the
+// record was found. Unlike Prefix().Bits(), this is not adjusted for IPv4
+// addresses, which are stored in the IPv6 search tree at ::/96. For instance,
+// the depth for the IPv4 network 1.1.1.0/24 is 120. This is intended for
+// tooling that inspects the search tree.
+//
+// The search tree of an IPv4 database has no nodes for the first 96 bits,
+// but its depths are reported in the same way so that they may be compared
+// with those of IPv6 databases. Subtract 96 to get the number of nodes
+// traversed in an IPv4 database.
+func (r Result) TreeDepth() int {
+	return int(r.prefixLen)
+}
+
+// PrefixString returns the network associated with the data record in CIDR
+// notation. If expanded is true, IPv6 addresses are written in full, with
+// leading zeros and without "::" compression, e.g.,
+// "2001:0db8:0000:0000:0000:0000:0000:0000/32". Otherwise, it is the same as
+// Prefix().String(). IPv4 networks are not affected by expanded.
+func (r Result) PrefixString(expanded bool) string {
+	prefix := r.Prefix()
+	if !expanded || !prefix.Addr().Is6() {
+		return prefix.String()
+	}
+	return prefix.Addr().StringExpanded() + "/" + strconv.Itoa(prefix.Bits())
+}
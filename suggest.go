@@ -0,0 +1,211 @@
+package maxminddb
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"math/big"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// SuggestStruct returns Go source for a struct type named typeName that the
+// record may be decoded into. Field names and types are inferred from the
+// decoded record and each field has the maxminddb tag for its key. Maps keyed
+// by language, i.e., "names" maps, are given the type map[string]string.
+// When an array contains maps, the struct for its elements has the union of
+// their keys.
+//
+// The suggestion is only based on a single record and is intended as a
+// starting point when writing a struct for a database. Values that are not
+// present in the record will not have a field.
+func (r Result) SuggestStruct(typeName string) (string, error) {
+	if !token.IsIdentifier(typeName) {
+		return "", fmt.Errorf("invalid type name %q", typeName)
+	}
+	if !r.Found() {
+		if err := r.Err(); err != nil {
+			return "", err
+		}
+		return "", errors.New("cannot suggest a struct for a record that was not found")
+	}
+
+	var record any
+	if err := r.Decode(&record); err != nil {
+		return "", err
+	}
+
+	shape := inferShape("", record)
+	if shape.fields == nil {
+		return "", fmt.Errorf("expected the record to be a map but found %s", shape.goType)
+	}
+
+	var b strings.Builder
+	b.WriteString("type " + typeName + " ")
+	shape.write(&b)
+	b.WriteString("\n")
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return "", fmt.Errorf("formatting suggested struct: %w", err)
+	}
+	return string(src), nil
+}
+
+// typeShape is the inferred Go type for a decoded value. Exactly one of
+// fields, for structs, elem, for slices, or goType, for everything else, is
+// set.
+type typeShape struct {
+	fields map[string]*typeShape
+	elem   *typeShape
+	goType string
+}
+
+func inferShape(key string, v any) *typeShape {
+	switch v := v.(type) {
+	case map[string]any:
+		if isStringMap(key, v) {
+			return &typeShape{goType: "map[string]string"}
+		}
+		fields := make(map[string]*typeShape, len(v))
+		for k, value := range v {
+			fields[k] = inferShape(k, value)
+		}
+		return &typeShape{fields: fields}
+	case []any:
+		var elem *typeShape
+		for _, value := range v {
+			elem = elem.merge(inferShape("", value))
+		}
+		if elem == nil {
+			elem = &typeShape{goType: "any"}
+		}
+		return &typeShape{elem: elem}
+	case string:
+		return &typeShape{goType: "string"}
+	case bool:
+		return &typeShape{goType: "bool"}
+	case []byte:
+		return &typeShape{goType: "[]byte"}
+	case float32:
+		return &typeShape{goType: "float32"}
+	case float64:
+		return &typeShape{goType: "float64"}
+	case int:
+		return &typeShape{goType: "int32"}
+	case uint64:
+		return &typeShape{goType: "uint64"}
+	case *big.Int:
+		return &typeShape{goType: "big.Int"}
+	default:
+		return &typeShape{goType: "any"}
+	}
+}
+
+// isStringMap returns true if the map should be suggested as a
+// map[string]string rather than a struct. This is the case for "names" maps
+// and for maps with keys that are not identifiers, e.g., "pt-BR".
+func isStringMap(key string, m map[string]any) bool {
+	if len(m) == 0 {
+		return false
+	}
+	identifierKeys := true
+	for k, v := range m {
+		if _, ok := v.(string); !ok {
+			return false
+		}
+		if !token.IsIdentifier(k) {
+			identifierKeys = false
+		}
+	}
+	return key == "names" || !identifierKeys
+}
+
+// merge combines two shapes inferred from elements of the same array. If
+// they are incompatible, the result is any.
+func (s *typeShape) merge(o *typeShape) *typeShape {
+	switch {
+	case s == nil:
+		return o
+	case s.fields != nil && o.fields != nil:
+		fields := make(map[string]*typeShape, len(s.fields))
+		for k, v := range s.fields {
+			fields[k] = v
+		}
+		for k, v := range o.fields {
+			fields[k] = fields[k].merge(v)
+		}
+		return &typeShape{fields: fields}
+	case s.elem != nil && o.elem != nil:
+		return &typeShape{elem: s.elem.merge(o.elem)}
+	case s.goType != "" && s.goType == o.goType:
+		return s
+	default:
+		return &typeShape{goType: "any"}
+	}
+}
+
+func (s *typeShape) write(b *strings.Builder) {
+	switch {
+	case s.fields != nil:
+		keys := make([]string, 0, len(s.fields))
+		for k := range s.fields {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+
+		b.WriteString("struct {\n")
+		// Different keys may have the same field name, e.g., "geoname_id"
+		// and "GeonameID", so a number is appended to the later ones.
+		used := make(map[string]bool, len(keys))
+		for _, k := range keys {
+			name := fieldName(k)
+			for i := 2; used[name]; i++ {
+				name = fmt.Sprintf("%s%d", fieldName(k), i)
+			}
+			used[name] = true
+			b.WriteString(name + " ")
+			s.fields[k].write(b)
+			fmt.Fprintf(b, " `maxminddb:%q`\n", k)
+		}
+		b.WriteString("}")
+	case s.elem != nil:
+		b.WriteString("[]")
+		s.elem.write(b)
+	default:
+		b.WriteString(s.goType)
+	}
+}
+
+var initialisms = map[string]string{
+	"asn": "ASN",
+	"id":  "ID",
+	"ip":  "IP",
+	"isp": "ISP",
+	"iso": "ISO",
+	"url": "URL",
+}
+
+// fieldName converts a key such as "geoname_id" into an exported Go
+// identifier such as "GeonameID".
+func fieldName(key string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if initialism, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(initialism)
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	name := b.String()
+	if name == "" || !unicode.IsUpper([]rune(name)[0]) {
+		name = "Field" + name
+	}
+	return name
+}
@@ -0,0 +1,66 @@
+package maxminddb
+
+import (
+	"go/parser"
+	"go/token"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestStruct(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	src, err := reader.Lookup(netip.MustParseAddr("81.2.69.142")).SuggestStruct("City")
+	require.NoError(t, err)
+
+	_, err = parser.ParseFile(token.NewFileSet(), "city.go", "package geoip\n\n"+src, 0)
+	require.NoError(t, err, src)
+
+	assert.Regexp(t, "^type City struct {\n", src)
+	assert.Regexp(t, "\tCity struct {\n", src)
+	assert.Regexp(t, "GeonameID +uint64 +`maxminddb:\"geoname_id\"`", src)
+	assert.Regexp(t, "Names +map\\[string\\]string +`maxminddb:\"names\"`", src)
+	assert.Regexp(t, "ISOCode +string +`maxminddb:\"iso_code\"`", src)
+	assert.Regexp(t, "Latitude +float64 +`maxminddb:\"latitude\"`", src)
+	assert.Regexp(t, "Subdivisions \\[\\]struct {\n", src)
+
+	_, err = reader.Lookup(netip.MustParseAddr("1.1.1.1")).SuggestStruct("City")
+	require.Error(t, err)
+
+	_, err = reader.Lookup(netip.MustParseAddr("81.2.69.142")).SuggestStruct("not a name")
+	require.Error(t, err)
+}
+
+func TestSuggestStructWithCollidingKeys(t *testing.T) {
+	// {"GeonameID": 1, "geoname_id": 2}
+	data := "e2" + "4947656f6e616d654944" + "a101" + "4a67656f6e616d655f6964" + "a102"
+	reader := twoNodeDatabase(t, "000012", "000002", data)
+	defer reader.Close()
+
+	src, err := reader.Lookup(netip.MustParseAddr("1.1.1.1")).SuggestStruct("Record")
+	require.NoError(t, err)
+
+	_, err = parser.ParseFile(token.NewFileSet(), "record.go", "package geoip\n\n"+src, 0)
+	require.NoError(t, err, src)
+
+	assert.Regexp(t, "\tGeonameID +uint64 +`maxminddb:\"GeonameID\"`", src)
+	assert.Regexp(t, "\tGeonameID2 +uint64 +`maxminddb:\"geoname_id\"`", src)
+}
+
+func TestFieldName(t *testing.T) {
+	for key, expected := range map[string]string{
+		"city":                     "City",
+		"geoname_id":               "GeonameID",
+		"iso_code":                 "ISOCode",
+		"autonomous_system_number": "AutonomousSystemNumber",
+		"pt-BR":                    "PtBR",
+		"2fa":                      "Field2fa",
+	} {
+		assert.Equal(t, expected, fieldName(key), key)
+	}
+}
@@ -1,10 +1,17 @@
 package maxminddb
 
 import (
+	"errors"
 	"fmt"
 	// comment to prevent gofumpt from randomly moving iter.
 	"iter"
+	"maps"
 	"net/netip"
+	"reflect"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
 )
 
 // Internal structure used to keep track of nodes we still need to visit.
@@ -15,10 +22,23 @@ type netNode struct {
 }
 
 type networkOptions struct {
+	maxNodes               uint
+	maxIPv4PrefixLen       int
+	maxIPv6PrefixLen       int
+	groupByRecord          bool
 	includeAliasedNetworks bool
 	includeEmptyNetworks   bool
+	// prefixesOnly is set by Prefixes. The data pointers of networks are
+	// not resolved, and the offset of each Result is its search tree record,
+	// which still identifies the record for GroupByRecord.
+	prefixesOnly bool
+	stackHint    int
 }
 
+// defaultStackHint is the initial capacity of the stack of search tree nodes
+// used by NetworksWithin.
+const defaultStackHint = 64
+
 var (
 	allIPv4 = netip.MustParsePrefix("0.0.0.0/0")
 	allIPv6 = netip.MustParsePrefix("::/0")
@@ -40,6 +60,79 @@ func IncludeNetworksWithoutData(networks *networkOptions) {
 	networks.includeEmptyNetworks = true
 }
 
+// GroupByRecord is an option for Networks and NetworksWithin that merges
+// consecutive networks with the same record into the network that covers
+// them when they exactly make up that network. For example, 1.0.0.0/25 and
+// 1.0.0.128/25 are yielded as 1.0.0.0/24 if both have the same record.
+// Networks that share a record but do not combine into a single network are
+// yielded separately. Use Result.Offset to identify the record.
+func GroupByRecord(networks *networkOptions) {
+	networks.groupByRecord = true
+}
+
+// MaxNodes returns an option for Networks and NetworksWithin that stops the
+// iteration with an error once more than n search tree nodes have been
+// visited. This guards against excessive work when traversing untrusted
+// databases. By default, or if n is zero, there is no limit.
+func MaxNodes(n uint) NetworksOption {
+	return func(networks *networkOptions) {
+		networks.maxNodes = n
+	}
+}
+
+// WithStackHint returns an option for Networks and NetworksWithin that sets
+// the initial capacity of the stack of search tree nodes that remain to be
+// visited. The stack grows as needed, so this only affects performance. For
+// a deep search tree, such as that of a large IPv6 database, a hint of 128
+// or more avoids growing the stack during the traversal. If n is zero or
+// less, the default of 64 is used.
+func WithStackHint(n int) NetworksOption {
+	return func(networks *networkOptions) {
+		networks.stackHint = n
+	}
+}
+
+// MaxPrefixLength returns an option for Networks and NetworksWithin that
+// stops descending the search tree at the given prefix lengths for IPv4 and
+// IPv6 networks respectively. A subtree below the limit is yielded as a
+// single covering network rather than as its individual networks. As such a
+// network may contain several records, its Result has no record and Found
+// returns false. Networks with data that are no longer than the limit are
+// yielded as usual. A limit of zero or less disables the limit for that IP
+// version.
+//
+// In an IPv6 database, the IPv6 limit does not apply to the networks that
+// contain the IPv4 subtree, ::/96, so that IPv4 networks are limited by the
+// IPv4 limit. As a result, the IPv6 networks next to the path to ::/96 may be
+// longer than the IPv6 limit.
+func MaxPrefixLength(ipv4, ipv6 int) NetworksOption {
+	return func(networks *networkOptions) {
+		networks.maxIPv4PrefixLen = ipv4
+		networks.maxIPv6PrefixLen = ipv6
+	}
+}
+
+// prefixLimit returns the bit depth at which to stop descending the search
+// tree below node, or zero if there is no limit.
+func (n *networkOptions) prefixLimit(node netNode) uint {
+	if isInIPv4Subtree(node.ip) {
+		if node.bit >= 96 {
+			if n.maxIPv4PrefixLen <= 0 {
+				return 0
+			}
+			return uint(n.maxIPv4PrefixLen) + 96
+		}
+		if node.ip.IsUnspecified() {
+			// The node contains the IPv4 subtree.
+			return 0
+		}
+	}
+	if n.maxIPv6PrefixLen <= 0 {
+		return 0
+	}
+	return uint(n.maxIPv6PrefixLen)
+}
+
 // Networks returns an iterator that can be used to traverse the networks in
 // the database.
 //
@@ -57,6 +150,219 @@ func (r *Reader) Networks(options ...NetworksOption) iter.Seq[Result] {
 	return r.NetworksWithin(allIPv4, options...)
 }
 
+// Prefixes returns an iterator over the networks in the database, as with
+// Networks, that yields only the network of each Result. This is convenient
+// when the records are not needed, e.g., when reporting the coverage of a
+// database. The options are the same as for Networks.
+//
+// As the records are never read, Prefixes is cheaper than Networks, but it
+// does not detect search tree records that point past the data section. Use
+// Verify to check for these.
+//
+// If an error is encountered, it is yielded with the network at which it
+// occurred, which may be invalid, and iteration stops.
+func (r *Reader) Prefixes(options ...NetworksOption) iter.Seq2[netip.Prefix, error] {
+	options = append(slices.Clip(options), prefixesOnly)
+	return func(yield func(netip.Prefix, error) bool) {
+		for result := range r.Networks(options...) {
+			err := result.Err()
+			if !yield(result.Prefix(), err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+func prefixesOnly(networks *networkOptions) {
+	networks.prefixesOnly = true
+}
+
+// PrefixRecord is a network in the database and the offset of its record.
+type PrefixRecord struct {
+	Prefix netip.Prefix
+	// RecordOffset is the offset of the record, as returned by Result.Offset.
+	// Networks that share a record have the same offset.
+	RecordOffset uintptr
+}
+
+// PrefixRecords returns an iterator over the networks in the database, as
+// with Networks, that yields each network with the offset of its record
+// rather than a Result. The options are the same as for Networks, so aliased
+// IPv4 networks and networks without data are excluded by default.
+//
+// If an error is encountered, it is yielded and iteration stops.
+func (r *Reader) PrefixRecords(options ...NetworksOption) iter.Seq2[PrefixRecord, error] {
+	return func(yield func(PrefixRecord, error) bool) {
+		for result := range r.Networks(options...) {
+			if err := result.Err(); err != nil {
+				yield(PrefixRecord{}, err)
+				return
+			}
+			record := PrefixRecord{Prefix: result.Prefix(), RecordOffset: result.Offset()}
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ExportPrefixes returns the networks in the database along with the offsets
+// of their records, in the order yielded by Networks. The offsets allow
+// networks to be served from another lookup engine while decoding each
+// record only once. The options are the same as for Networks.
+//
+// This holds every network in memory. Use PrefixRecords to process the
+// networks one at a time instead.
+func (r *Reader) ExportPrefixes(options ...NetworksOption) ([]PrefixRecord, error) {
+	var records []PrefixRecord
+	for record, err := range r.PrefixRecords(options...) {
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// HasAliasedNetworks reports whether the IPv4 subtree of the database is
+// also reachable from other locations in the IPv6 search tree, e.g.,
+// ::ffff:0:0/96, 2001::/32, or 2002::/16. In other words, it reports whether
+// Networks yields more networks with the [IncludeAliasedNetworks] option
+// than without it. It always returns false for IPv4 databases.
+//
+// This traverses the entire search tree and should not be called on a hot
+// path.
+func (r *Reader) HasAliasedNetworks() (bool, error) {
+	if r.Metadata.IPVersion != 6 || r.ipv4Start == 0 {
+		return false, nil
+	}
+
+	count := func(options ...NetworksOption) (int, error) {
+		n := 0
+		for result := range r.Networks(options...) {
+			if err := result.Err(); err != nil {
+				return 0, err
+			}
+			n++
+		}
+		return n, nil
+	}
+
+	withoutAliases, err := count()
+	if err != nil {
+		return false, err
+	}
+	withAliases, err := count(IncludeAliasedNetworks)
+	if err != nil {
+		return false, err
+	}
+	return withAliases != withoutAliases, nil
+}
+
+// ColumnSelector selects a value for NetworksColumnar.
+type ColumnSelector struct {
+	// Path is the path to the value, as used by Result.DecodePath.
+	Path []any
+	// Type is the type that the value is decoded into. If it is nil, the
+	// value is decoded as with a destination of type any.
+	Type reflect.Type
+}
+
+// NetworksColumnar iterates over the networks in the database, as with
+// Networks, and calls fn with the prefix and the values selected by
+// selectors for each network. The value at cols[i] is decoded using
+// selectors[i] and is nil if the path is not found in the record. Iteration
+// stops at the first error returned by fn or encountered while decoding.
+//
+// The cols slice is reused between calls and must not be retained by fn.
+// This is intended for loading data into columnar builders without decoding
+// each record into an intermediate struct.
+func (r *Reader) NetworksColumnar(
+	selectors []ColumnSelector,
+	fn func(prefix netip.Prefix, cols []any) error,
+	options ...NetworksOption,
+) error {
+	cols := make([]any, len(selectors))
+	for result := range r.Networks(options...) {
+		if err := result.Err(); err != nil {
+			return err
+		}
+		for i, selector := range selectors {
+			var err error
+			cols[i], err = result.decodeColumn(selector)
+			if err != nil {
+				return fmt.Errorf("decoding column %d of %s: %w", i, result.Prefix(), err)
+			}
+		}
+		if err := fn(result.Prefix(), cols); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r Result) decodeColumn(selector ColumnSelector) (value any, err error) {
+	defer r.recoverPanic(&err)
+	if r.offset == notFound {
+		return nil, nil
+	}
+	offset, found, err := r.decoder.followPath(r.offset, selector.Path)
+	if err != nil || !found {
+		return nil, err
+	}
+	typ := selector.Type
+	if typ == nil {
+		typ = reflect.TypeFor[any]()
+	}
+	v := reflect.New(typ)
+	if _, err := r.decoder.decode(offset, v, len(selector.Path)); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// NetworksGroupedBy iterates over the networks in the database, as with
+// Networks, and groups them by the value at selector, which is a path as
+// used by Result.DecodePath. The value is converted to a string with
+// fmt.Sprint. Networks whose records do not have a value at selector are
+// omitted.
+//
+// All of the networks are read before the returned iterator is, so that
+// any error can be returned. The iterator yields each value once, in sorted
+// order, with its networks in the order that Networks returned them. This is
+// intended for reports such as a list of networks per country and is not
+// suitable for a hot path.
+func (r *Reader) NetworksGroupedBy(
+	selector []any,
+	options ...NetworksOption,
+) (iter.Seq2[string, []netip.Prefix], error) {
+	groups := map[string][]netip.Prefix{}
+	for result := range r.Networks(options...) {
+		if err := result.Err(); err != nil {
+			return nil, err
+		}
+		var value any
+		found, err := result.DecodePathExists(&value, selector...)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", result.Prefix(), err)
+		}
+		if !found {
+			continue
+		}
+		key := fmt.Sprint(value)
+		groups[key] = append(groups[key], result.Prefix())
+	}
+
+	keys := slices.Sorted(maps.Keys(groups))
+	return func(yield func(string, []netip.Prefix) bool) {
+		for _, key := range keys {
+			if !yield(key, groups[key]) {
+				return
+			}
+		}
+	}, nil
+}
+
 // NetworksWithin returns an iterator that can be used to traverse the networks
 // in the database which are contained in a given prefix.
 //
@@ -86,105 +392,336 @@ func (r *Reader) NetworksWithin(prefix netip.Prefix, options ...NetworksOption)
 		for _, option := range options {
 			option(n)
 		}
-
-		ip := prefix.Addr()
-		netIP := ip
-		stopBit := prefix.Bits()
-		if ip.Is4() {
-			netIP = v4ToV16(ip)
-			stopBit += 96
+		if r.decoder.opts.recoverPanics {
+			// Only panics from the traversal are recovered. Those from the
+			// loop body must be passed on.
+			loopBody := yield
+			inLoopBody := false
+			yield = func(result Result) bool {
+				inLoopBody = true
+				ok := loopBody(result)
+				inLoopBody = false
+				return ok
+			}
+			defer func() {
+				if p := recover(); p != nil {
+					if inLoopBody {
+						panic(p)
+					}
+					loopBody(Result{
+						err: newInvalidDatabaseError("panic while traversing networks: %v", p),
+					})
+				}
+			}()
+		}
+		if n.groupByRecord {
+			g := &recordGrouper{yield: yield}
+			yield = g.add
+			defer g.flush()
 		}
 
-		pointer, bit := r.traverseTree(ip, 0, stopBit)
-
-		prefix, err := netIP.Prefix(bit)
+		start, err := r.startNode(prefix)
 		if err != nil {
 			yield(Result{
-				ip:        ip,
-				prefixLen: uint8(bit),
-				err:       fmt.Errorf("prefixing %s with %d", netIP, bit),
+				ip:        prefix.Addr(),
+				prefixLen: uint8(start.bit),
+				err:       err,
 			})
 		}
+		r.walk(start, n, nil, yield)
+	}
+}
 
-		nodes := make([]netNode, 0, 64)
-		nodes = append(nodes,
-			netNode{
-				ip:      prefix.Addr(),
-				bit:     uint(bit),
-				pointer: pointer,
-			},
-		)
-
-		for len(nodes) > 0 {
-			node := nodes[len(nodes)-1]
-			nodes = nodes[:len(nodes)-1]
-
-			for {
-				if node.pointer == r.Metadata.NodeCount {
-					if n.includeEmptyNetworks {
-						ok := yield(Result{
-							ip:        mappedIP(node.ip),
-							offset:    notFound,
-							prefixLen: uint8(node.bit),
-						})
-						if !ok {
-							return
-						}
-					}
-					break
-				}
-				// This skips IPv4 aliases without hardcoding the networks that the writer
-				// currently aliases.
-				if !n.includeAliasedNetworks && r.ipv4Start != 0 &&
-					node.pointer == r.ipv4Start && !isInIPv4Subtree(node.ip) {
-					break
-				}
+// startNode returns the search tree node for prefix, or for the network
+// containing it if the tree has data for a larger network.
+func (r *Reader) startNode(prefix netip.Prefix) (netNode, error) {
+	ip := prefix.Addr()
+	netIP := ip
+	stopBit := prefix.Bits()
+	if ip.Is4() {
+		netIP = v4ToV16(ip)
+		stopBit += 96
+	}
 
-				if node.pointer > r.Metadata.NodeCount {
-					offset, err := r.resolveDataPointer(node.pointer)
+	pointer, bit := r.traverseTree(ip, 0, stopBit)
+
+	prefix, err := netIP.Prefix(bit)
+	if err != nil {
+		return netNode{bit: uint(bit)}, fmt.Errorf("prefixing %s with %d", netIP, bit)
+	}
+	return netNode{
+		ip:      prefix.Addr(),
+		bit:     uint(bit),
+		pointer: pointer,
+	}, nil
+}
+
+// walk yields the networks in the subtree rooted at start. If split is not
+// nil, the nodes that NetworksParallel traverses separately are passed to it
+// rather than being descended into. walk returns false if the iteration was
+// stopped by yield or by an error.
+func (r *Reader) walk(
+	start netNode,
+	n *networkOptions,
+	split func(netNode),
+	yield func(Result) bool,
+) bool {
+	var visitedNodes uint
+	stackHint := n.stackHint
+	if stackHint <= 0 {
+		stackHint = defaultStackHint
+	}
+	nodes := make([]netNode, 0, stackHint)
+	nodes = append(nodes, start)
+
+	for len(nodes) > 0 {
+		node := nodes[len(nodes)-1]
+		nodes = nodes[:len(nodes)-1]
+
+		for {
+			if node.pointer == r.Metadata.NodeCount {
+				if n.includeEmptyNetworks {
 					ok := yield(Result{
-						decoder:   r.decoder,
 						ip:        mappedIP(node.ip),
-						offset:    uint(offset),
+						offset:    notFound,
 						prefixLen: uint8(node.bit),
-						err:       err,
 					})
 					if !ok {
-						return
+						return false
 					}
-					break
 				}
-				ipRight := node.ip.As16()
-				if len(ipRight) <= int(node.bit>>3) {
-					displayAddr := node.ip
-					if isInIPv4Subtree(node.ip) {
-						displayAddr = v6ToV4(displayAddr)
-					}
-
-					res := Result{
-						ip:        displayAddr,
-						prefixLen: uint8(node.bit),
-					}
-					res.err = newInvalidDatabaseError(
-						"invalid search tree at %s", res.Prefix())
+				break
+			}
+			// This skips IPv4 aliases without hardcoding the networks that the writer
+			// currently aliases.
+			if !n.includeAliasedNetworks && r.ipv4Start != 0 &&
+				node.pointer == r.ipv4Start && !isInIPv4Subtree(node.ip) {
+				break
+			}
 
-					yield(res)
+			if node.pointer > r.Metadata.NodeCount {
+				result := Result{
+					ip:        mappedIP(node.ip),
+					offset:    node.pointer,
+					prefixLen: uint8(node.bit),
+				}
+				if !n.prefixesOnly {
+					offset, err := r.resolveDataPointer(node.pointer)
+					result.decoder = r.decoder
+					result.offset = uint(offset)
+					result.err = err
+				}
+				if !yield(result) {
+					return false
+				}
+				break
+			}
+			ipRight := node.ip.As16()
+			if len(ipRight) <= int(node.bit>>3) {
+				displayAddr := node.ip
+				if isInIPv4Subtree(node.ip) {
+					displayAddr = v6ToV4(displayAddr)
+				}
 
-					return
+				res := Result{
+					ip:        displayAddr,
+					prefixLen: uint8(node.bit),
 				}
-				ipRight[node.bit>>3] |= 1 << (7 - (node.bit % 8))
+				res.err = newInvalidDatabaseError(
+					"invalid search tree at %s", res.Prefix())
 
-				offset := node.pointer * r.nodeOffsetMult
-				rightPointer := r.nodeReader.readRight(offset)
+				yield(res)
 
-				node.bit++
-				nodes = append(nodes, netNode{
-					pointer: rightPointer,
-					ip:      netip.AddrFrom16(ipRight),
-					bit:     node.bit,
+				return false
+			}
+			if limit := n.prefixLimit(node); limit > 0 && node.bit >= limit {
+				ok := yield(Result{
+					ip:        mappedIP(node.ip),
+					offset:    notFound,
+					prefixLen: uint8(node.bit),
 				})
+				if !ok {
+					return false
+				}
+				break
+			}
+			if split != nil && isParallelSplit(node) {
+				split(node)
+				break
+			}
+			visitedNodes++
+			if n.maxNodes > 0 && visitedNodes > n.maxNodes {
+				yield(Result{
+					ip:        mappedIP(node.ip),
+					prefixLen: uint8(node.bit),
+					err: fmt.Errorf(
+						"error traversing networks: visited more than the maximum of %d nodes",
+						n.maxNodes,
+					),
+				})
+				return false
+			}
+
+			ipRight[node.bit>>3] |= 1 << (7 - (node.bit % 8))
+
+			offset := node.pointer * r.nodeOffsetMult
+			rightPointer := r.nodeReader.readRight(offset)
+
+			node.bit++
+			nodes = append(nodes, netNode{
+				pointer: rightPointer,
+				ip:      netip.AddrFrom16(ipRight),
+				bit:     node.bit,
+			})
+
+			node.pointer = r.nodeReader.readLeft(offset)
+		}
+	}
+	return true
+}
+
+// parallelSplitBits is the depth below the root of the IPv4 and IPv6 trees
+// at which NetworksParallel splits the search tree into subtrees.
+const parallelSplitBits = 8
+
+// isParallelSplit returns true if NetworksParallel traverses the subtree
+// rooted at node separately. This happens at parallelSplitBits below the root
+// of the IPv4 subtree and, for other IPv6 networks, below the root of the
+// tree. The nodes that lead to the IPv4 subtree are never split off.
+func isParallelSplit(node netNode) bool {
+	if isInIPv4Subtree(node.ip) {
+		if node.bit >= 96 {
+			return node.bit >= 96+parallelSplitBits
+		}
+		if node.ip.IsUnspecified() {
+			return false
+		}
+	}
+	return node.bit >= parallelSplitBits
+}
+
+// parallelResult is sent from the goroutines of NetworksParallel to the
+// iterating goroutine.
+type parallelResult struct {
+	result     Result
+	panicValue any
+	panicked   bool
+}
+
+// NetworksParallel returns an iterator over the networks in the database,
+// as with Networks, where the search tree is split into subtrees that are
+// traversed concurrently by up to workers goroutines. If workers is zero or
+// less, runtime.GOMAXPROCS(0) is used. The Results are yielded to the
+// iterating goroutine, but not in any particular order.
+//
+// This may be faster than Networks when the loop body is cheap relative to
+// the traversal, e.g., when exporting the networks of a large database. The
+// options are the same as for Networks, except that MaxNodes and
+// GroupByRecord apply to each subtree separately. As such, networks that
+// span subtrees are not merged by GroupByRecord.
+//
+// If an error is encountered, a Result with the error is yielded and
+// iteration stops. As the subtrees are traversed concurrently, which error is
+// yielded for a database with several problems may vary.
+func (r *Reader) NetworksParallel(workers int, options ...NetworksOption) iter.Seq[Result] {
+	return func(yield func(Result) bool) {
+		if r.buffer == nil {
+			yield(Result{err: errors.New("cannot call NetworksParallel on a closed database")})
+			return
+		}
+		if workers <= 0 {
+			workers = runtime.GOMAXPROCS(0)
+		}
+		n := &networkOptions{}
+		for _, option := range options {
+			option(n)
+		}
+		prefix := allIPv4
+		if r.Metadata.IPVersion == 6 {
+			prefix = allIPv6
+		}
+
+		results := make(chan parallelResult, workers)
+		done := make(chan struct{})
+		defer func() {
+			// Wait for the goroutines to stop so that none are reading the
+			// database after the iteration has ended, e.g., when the
+			// Reader is then closed.
+			close(done)
+			for range results {
+			}
+		}()
 
-				node.pointer = r.nodeReader.readLeft(offset)
+		send := func(result parallelResult) bool {
+			select {
+			case results <- result:
+				return true
+			case <-done:
+				return false
+			}
+		}
+		// run yields the networks of the subtree rooted at start and returns
+		// false if the iteration was stopped.
+		run := func(start netNode, split func(netNode)) (ok bool) {
+			defer func() {
+				if p := recover(); p != nil {
+					send(parallelResult{panicValue: p, panicked: true})
+					ok = false
+				}
+			}()
+			sendResult := func(result Result) bool {
+				return send(parallelResult{result: result}) && result.err == nil
+			}
+			if n.groupByRecord {
+				g := &recordGrouper{yield: sendResult}
+				sendResult = g.add
+				defer g.flush()
+			}
+			return r.walk(start, n, split, sendResult)
+		}
+
+		go func() {
+			defer close(results)
+
+			start, err := r.startNode(prefix)
+			if err != nil {
+				send(parallelResult{result: Result{err: err}})
+				return
+			}
+			var subtrees []netNode
+			if !run(start, func(node netNode) { subtrees = append(subtrees, node) }) {
+				return
+			}
+
+			var next atomic.Int64
+			var wg sync.WaitGroup
+			for range min(workers, len(subtrees)) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						i := int(next.Add(1)) - 1
+						if i >= len(subtrees) || !run(subtrees[i], nil) {
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		}()
+
+		for result := range results {
+			if result.panicked {
+				if !r.decoder.opts.recoverPanics {
+					panic(result.panicValue)
+				}
+				yield(Result{
+					err: newInvalidDatabaseError("panic while traversing networks: %v", result.panicValue),
+				})
+				return
+			}
+			if !yield(result.result) || result.result.err != nil {
+				return
 			}
 		}
 	}
@@ -192,6 +729,62 @@ func (r *Reader) NetworksWithin(prefix netip.Prefix, options ...NetworksOption)
 
 var ipv4SubtreeBoundary = netip.MustParseAddr("::255.255.255.255").Next()
 
+// recordGrouper merges the networks passed to add for GroupByRecord. The
+// pending networks all have the same record and are merged with each other
+// as soon as possible.
+type recordGrouper struct {
+	yield   func(Result) bool
+	pending []Result
+	stopped bool
+}
+
+func (g *recordGrouper) add(result Result) bool {
+	if result.err != nil || (len(g.pending) > 0 && g.pending[0].offset != result.offset) {
+		if !g.flush() {
+			return false
+		}
+	}
+	if result.err != nil {
+		g.stopped = !g.yield(result)
+		return !g.stopped
+	}
+
+	g.pending = append(g.pending, result)
+	for len(g.pending) >= 2 {
+		last := len(g.pending) - 1
+		left := treePrefix(g.pending[last-1])
+		right := treePrefix(g.pending[last])
+		if left.Bits() != right.Bits() || left.Bits() == 0 {
+			break
+		}
+		parent := netip.PrefixFrom(left.Addr(), left.Bits()-1).Masked()
+		if parent != netip.PrefixFrom(right.Addr(), right.Bits()-1).Masked() {
+			break
+		}
+		merged := g.pending[last-1]
+		merged.ip = mappedIP(parent.Addr())
+		merged.prefixLen = uint8(parent.Bits())
+		g.pending = append(g.pending[:last-1], merged)
+	}
+	return true
+}
+
+// flush yields the pending networks. It returns false if the iteration was
+// stopped.
+func (g *recordGrouper) flush() bool {
+	if g.stopped {
+		return false
+	}
+	for _, result := range g.pending {
+		if !g.yield(result) {
+			g.stopped = true
+			return false
+		}
+	}
+	g.pending = g.pending[:0]
+	return true
+}
+
 func mappedIP(ip netip.Addr) netip.Addr {
 	if isInIPv4Subtree(ip) {
 		return v6ToV4(ip)
@@ -1,10 +1,13 @@
 package maxminddb
 
 import (
+	"errors"
 	"fmt"
+	"iter"
 	"net/netip"
 	"reflect"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -55,6 +58,56 @@ func TestNetworksWithInvalidSearchTree(t *testing.T) {
 	require.NoError(t, reader.Close())
 }
 
+func TestNetworksWithMaxNodes(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var count int
+	for result := range reader.Networks(MaxNodes(10)) {
+		err = result.Err()
+		if err != nil {
+			break
+		}
+		count++
+	}
+	require.EqualError(
+		t,
+		err,
+		"error traversing networks: visited more than the maximum of 10 nodes",
+	)
+
+	var unlimited int
+	for result := range reader.Networks(MaxNodes(reader.Metadata.NodeCount)) {
+		require.NoError(t, result.Err())
+		unlimited++
+	}
+	assert.Less(t, count, unlimited)
+}
+
+func TestHasAliasedNetworks(t *testing.T) {
+	for _, test := range []struct {
+		Database string
+		Expected bool
+	}{
+		{"MaxMind-DB-test-ipv4-24.mmdb", false},
+		{"MaxMind-DB-test-mixed-24.mmdb", true},
+		{"MaxMind-DB-test-decoder.mmdb", true},
+		{"MaxMind-DB-no-ipv4-search-tree.mmdb", false},
+	} {
+		t.Run(test.Database, func(t *testing.T) {
+			reader, err := Open(testFile(test.Database))
+			require.NoError(t, err)
+
+			hasAliases, err := reader.HasAliasedNetworks()
+			require.NoError(t, err)
+			assert.Equal(t, test.Expected, hasAliases)
+
+			require.NoError(t, reader.Close())
+		})
+	}
+}
+
 type networkTest struct {
 	Network  string
 	Database string
@@ -385,6 +438,57 @@ func TestGeoIPNetworksWithin(t *testing.T) {
 	}
 }
 
+func TestNetworksColumnar(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-Country-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	selectors := []ColumnSelector{
+		{Path: []any{"country", "iso_code"}, Type: reflect.TypeFor[string]()},
+		{Path: []any{"registered_country", "geoname_id"}},
+	}
+
+	var prefixes []netip.Prefix
+	var isoCodes, geonameIDs []any
+	err = reader.NetworksColumnar(selectors, func(prefix netip.Prefix, cols []any) error {
+		prefixes = append(prefixes, prefix)
+		isoCodes = append(isoCodes, cols[0])
+		geonameIDs = append(geonameIDs, cols[1])
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, prefixes)
+
+	i := 0
+	for result := range reader.Networks() {
+		require.NoError(t, result.Err())
+		assert.Equal(t, result.Prefix(), prefixes[i])
+
+		var isoCode string
+		require.NoError(t, result.DecodePath(&isoCode, "country", "iso_code"))
+		if isoCode == "" {
+			assert.Nil(t, isoCodes[i], prefixes[i])
+		} else {
+			assert.Equal(t, isoCode, isoCodes[i], prefixes[i])
+		}
+
+		var geonameID any
+		require.NoError(t, result.DecodePath(&geonameID, "registered_country", "geoname_id"))
+		assert.Equal(t, geonameID, geonameIDs[i], prefixes[i])
+		i++
+	}
+	assert.Len(t, prefixes, i)
+
+	errStop := errors.New("stop")
+	calls := 0
+	err = reader.NetworksColumnar(selectors, func(netip.Prefix, []any) error {
+		calls++
+		return errStop
+	})
+	require.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls)
+}
+
 func BenchmarkNetworks(b *testing.B) {
 	db, err := Open(testFile("GeoIP2-Country-Test.mmdb"))
 	require.NoError(b, err)
@@ -400,3 +504,425 @@ func BenchmarkNetworks(b *testing.B) {
 	}
 	require.NoError(b, db.Close(), "error on close")
 }
+
+func TestNetworksWithStackHint(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-ipv6-32.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var expected []netip.Prefix
+	for result := range reader.Networks() {
+		require.NoError(t, result.Err())
+		expected = append(expected, result.Prefix())
+	}
+	require.NotEmpty(t, expected)
+
+	for _, hint := range []int{-1, 0, 1, 128} {
+		var prefixes []netip.Prefix
+		for result := range reader.Networks(WithStackHint(hint)) {
+			require.NoError(t, result.Err())
+			prefixes = append(prefixes, result.Prefix())
+		}
+		assert.Equal(t, expected, prefixes, "hint %d", hint)
+	}
+}
+
+// BenchmarkNetworksWithStackHint compares the allocations of a full
+// traversal of a large database with the default and a tuned stack hint.
+func BenchmarkNetworksWithStackHint(b *testing.B) {
+	db, err := Open("GeoLite2-City.mmdb")
+	require.NoError(b, err)
+
+	for _, hint := range []int{0, 128} {
+		b.Run(fmt.Sprintf("hint %d", hint), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for r := range db.Networks(WithStackHint(hint)) {
+					if err := r.Err(); err != nil {
+						b.Error(err)
+					}
+				}
+			}
+		})
+	}
+	require.NoError(b, db.Close(), "error on close")
+}
+
+func TestNetworksParallel(t *testing.T) {
+	type network struct {
+		prefix netip.Prefix
+		offset uintptr
+	}
+	collect := func(t *testing.T, seq iter.Seq[Result]) []network {
+		var networks []network
+		for result := range seq {
+			require.NoError(t, result.Err())
+			networks = append(networks, network{result.Prefix(), result.Offset()})
+		}
+		return networks
+	}
+	compare := func(a, b network) int {
+		if c := a.prefix.Addr().Compare(b.prefix.Addr()); c != 0 {
+			return c
+		}
+		return a.prefix.Bits() - b.prefix.Bits()
+	}
+
+	for _, file := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-ipv6-32.mmdb",
+		"MaxMind-DB-test-mixed-28.mmdb",
+		"MaxMind-DB-no-ipv4-search-tree.mmdb",
+	} {
+		reader, err := Open(testFile(file))
+		require.NoError(t, err)
+
+		for name, options := range map[string][]NetworksOption{
+			"default":       nil,
+			"aliased":       {IncludeAliasedNetworks},
+			"without data":  {IncludeNetworksWithoutData},
+			"prefix length": {MaxPrefixLength(16, 32)},
+		} {
+			t.Run(file+" "+name, func(t *testing.T) {
+				expected := collect(t, reader.Networks(options...))
+				require.NotEmpty(t, expected)
+
+				for _, workers := range []int{0, 1, 3} {
+					networks := collect(t, reader.NetworksParallel(workers, options...))
+					slices.SortFunc(networks, compare)
+					assert.Equal(t, expected, networks, "workers %d", workers)
+				}
+			})
+		}
+		require.NoError(t, reader.Close())
+	}
+}
+
+func TestNetworksParallelStop(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	count := 0
+	for range reader.NetworksParallel(4) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	assert.Equal(t, 3, count)
+
+	broken, err := Open(testFile("MaxMind-DB-test-broken-search-tree-24.mmdb"))
+	require.NoError(t, err)
+	defer broken.Close()
+
+	var lastErr error
+	for result := range broken.NetworksParallel(2) {
+		lastErr = result.Err()
+	}
+	// The broken database has several errors, and which is found first
+	// depends on the scheduling of the goroutines.
+	require.ErrorAs(t, lastErr, &InvalidDatabaseError{})
+
+	require.NoError(t, reader.Close())
+	for result := range reader.NetworksParallel(2) {
+		require.EqualError(t, result.Err(), "cannot call NetworksParallel on a closed database")
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	reader, err := Open(testFile("MaxMind-DB-test-mixed-24.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	for name, options := range map[string][]NetworksOption{
+		"default":      nil,
+		"aliased":      {IncludeAliasedNetworks},
+		"without data": {IncludeNetworksWithoutData},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var expected []netip.Prefix
+			for result := range reader.Networks(options...) {
+				require.NoError(t, result.Err())
+				expected = append(expected, result.Prefix())
+			}
+			require.NotEmpty(t, expected)
+
+			var prefixes []netip.Prefix
+			for prefix, err := range reader.Prefixes(options...) {
+				require.NoError(t, err)
+				prefixes = append(prefixes, prefix)
+			}
+			assert.Equal(t, expected, prefixes)
+		})
+	}
+
+	var errs []error
+	for _, err := range reader.Prefixes(MaxNodes(1)) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	require.Len(t, errs, 1)
+	assert.EqualError(
+		t,
+		errs[0],
+		"error traversing networks: visited more than the maximum of 1 nodes",
+	)
+
+	// The data pointers are not resolved, so search tree records that point
+	// past the data section are not reported.
+	broken, err := Open(testFile("MaxMind-DB-test-broken-search-tree-24.mmdb"))
+	require.NoError(t, err)
+	defer broken.Close()
+
+	for _, err := range broken.Prefixes() {
+		require.NoError(t, err)
+	}
+}
+
+func BenchmarkPrefixes(b *testing.B) {
+	db, err := Open(testFile("GeoIP2-Country-Test.mmdb"))
+	require.NoError(b, err)
+
+	b.Run("Networks", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for r := range db.Networks() {
+				if err := r.Err(); err != nil {
+					b.Error(err)
+				}
+				_ = r.Prefix()
+			}
+		}
+	})
+	b.Run("Prefixes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, err := range db.Prefixes() {
+				if err != nil {
+					b.Error(err)
+				}
+			}
+		}
+	})
+	require.NoError(b, db.Close(), "error on close")
+}
+
+func TestExportPrefixes(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var expected []PrefixRecord
+	for result := range reader.Networks() {
+		require.NoError(t, result.Err())
+		expected = append(expected, PrefixRecord{result.Prefix(), result.Offset()})
+	}
+	require.NotEmpty(t, expected)
+
+	records, err := reader.ExportPrefixes()
+	require.NoError(t, err)
+	assert.Equal(t, expected, records)
+
+	withAliases, err := reader.ExportPrefixes(IncludeAliasedNetworks)
+	require.NoError(t, err)
+	assert.Greater(t, len(withAliases), len(records))
+
+	for _, record := range records {
+		result := reader.LookupOffset(record.RecordOffset)
+		require.NoError(t, result.Err())
+		var city fullCity
+		require.NoError(t, result.Decode(&city))
+	}
+
+	broken, err := Open(testFile("MaxMind-DB-test-broken-search-tree-24.mmdb"))
+	require.NoError(t, err)
+	defer broken.Close()
+
+	_, err = broken.ExportPrefixes()
+	require.ErrorAs(t, err, &InvalidDatabaseError{})
+
+	var errs []error
+	for _, err := range broken.PrefixRecords() {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	require.Len(t, errs, 1)
+}
+
+func TestNetworksGroupedBy(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-Connection-Type-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	groups, err := reader.NetworksGroupedBy([]any{"connection_type"})
+	require.NoError(t, err)
+
+	var keys []string
+	count := 0
+	for connectionType, prefixes := range groups {
+		keys = append(keys, connectionType)
+		require.NotEmpty(t, prefixes)
+		for _, prefix := range prefixes {
+			var actual string
+			require.NoError(
+				t,
+				reader.Lookup(prefix.Addr()).DecodePath(&actual, "connection_type"),
+			)
+			assert.Equal(t, connectionType, actual, prefix)
+		}
+		count += len(prefixes)
+	}
+	assert.True(t, slices.IsSorted(keys))
+	assert.Contains(t, keys, "Cable/DSL")
+	assert.Contains(t, keys, "Cellular")
+
+	total := 0
+	for range reader.Networks() {
+		total++
+	}
+	assert.Equal(t, total, count)
+
+	groups, err = reader.NetworksGroupedBy([]any{"does-not-exist"})
+	require.NoError(t, err)
+	for range groups {
+		t.Fatal("expected no groups")
+	}
+}
+
+func TestNetworksWithMaxPrefixLength(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			var coarse []Result
+			for result := range reader.Networks(MaxPrefixLength(16, 32)) {
+				require.NoError(t, result.Err())
+				coarse = append(coarse, result)
+			}
+			require.NotEmpty(t, coarse)
+
+			covering := 0
+			for _, result := range coarse {
+				prefix := result.Prefix()
+				if prefix.Addr().Is4() {
+					assert.LessOrEqual(t, prefix.Bits(), 16, prefix)
+				} else if !netip.MustParsePrefix("::/32").Contains(prefix.Addr()) {
+					assert.LessOrEqual(t, prefix.Bits(), 32, prefix)
+				}
+				if !result.Found() {
+					covering++
+				}
+			}
+			assert.NotZero(t, covering)
+
+			for network := range reader.Networks() {
+				require.NoError(t, network.Err())
+				prefix := network.Prefix()
+				i := slices.IndexFunc(coarse, func(r Result) bool {
+					return r.Prefix().Overlaps(prefix)
+				})
+				require.NotEqual(t, -1, i, "%s is not covered", prefix)
+				if coarse[i].Found() {
+					// Networks no longer than the limit are unchanged.
+					assert.Equal(t, prefix, coarse[i].Prefix())
+					assert.Equal(t, network.Offset(), coarse[i].Offset())
+				} else {
+					assert.True(t, coarse[i].Prefix().Bits() < prefix.Bits(), prefix)
+				}
+			}
+
+			var all []Result
+			for result := range reader.Networks(MaxPrefixLength(0, 0)) {
+				all = append(all, result)
+			}
+			var expected []Result
+			for result := range reader.Networks() {
+				expected = append(expected, result)
+			}
+			assert.Equal(t, expected, all)
+		})
+	}
+}
+
+func TestNetworksGroupByRecord(t *testing.T) {
+	// 0.0.0.0/2 and 64.0.0.0/2 have the same record.
+	reader := twoNodeDatabase(t, "000012", "000012", "e141610107")
+
+	var prefixes []netip.Prefix
+	for result := range reader.Networks() {
+		require.NoError(t, result.Err())
+		prefixes = append(prefixes, result.Prefix())
+	}
+	assert.Equal(
+		t,
+		[]netip.Prefix{
+			netip.MustParsePrefix("0.0.0.0/2"),
+			netip.MustParsePrefix("64.0.0.0/2"),
+		},
+		prefixes,
+	)
+
+	var grouped []Result
+	for result := range reader.Networks(GroupByRecord) {
+		require.NoError(t, result.Err())
+		grouped = append(grouped, result)
+	}
+	require.Len(t, grouped, 1)
+	assert.Equal(t, netip.MustParsePrefix("0.0.0.0/1"), grouped[0].Prefix())
+	var record map[string]bool
+	require.NoError(t, grouped[0].Decode(&record))
+	assert.Equal(t, map[string]bool{"a": true}, record)
+
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			var grouped []Result
+			for result := range reader.Networks(GroupByRecord, IncludeNetworksWithoutData) {
+				require.NoError(t, result.Err())
+				grouped = append(grouped, result)
+			}
+
+			count := 0
+			for network := range reader.Networks(IncludeNetworksWithoutData) {
+				require.NoError(t, network.Err())
+				count++
+				i := slices.IndexFunc(grouped, func(r Result) bool {
+					return r.Prefix().Overlaps(network.Prefix())
+				})
+				require.NotEqual(t, -1, i, network.Prefix())
+				assert.True(t, grouped[i].Prefix().Bits() <= network.Prefix().Bits())
+				assert.Equal(t, network.Offset(), grouped[i].Offset(), network.Prefix())
+			}
+			assert.LessOrEqual(t, len(grouped), count)
+
+			for i := 1; i < len(grouped); i++ {
+				assert.False(t, grouped[i-1].Prefix().Overlaps(grouped[i].Prefix()))
+			}
+
+			// Stopping early does not yield further networks.
+			n := 0
+			for range reader.Networks(GroupByRecord) {
+				n++
+				break
+			}
+			assert.Equal(t, 1, n)
+		})
+	}
+}
@@ -1,28 +1,280 @@
 package maxminddb
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
 	"reflect"
 	"runtime"
+	"slices"
 )
 
 type verifier struct {
 	reader *Reader
+	ctx    context.Context
+	// checkInterval is how many networks or values are verified between
+	// checks of ctx.
+	checkInterval int
+	report        VerifyReport
 }
 
+func newVerifier(r *Reader, ctx context.Context) verifier {
+	return verifier{reader: r, ctx: ctx, checkInterval: verifyContextCheckInterval}
+}
+
+// VerifyReport summarizes a database checked by Reader.VerifyReport.
+type VerifyReport struct {
+	// Warnings describe issues that do not make the database invalid, such
+	// as missing optional metadata.
+	Warnings []string
+	// Networks is the number of networks with data, as yielded by
+	// Reader.Networks.
+	Networks int
+	// EmptyNetworks is the number of networks without data, as yielded in
+	// addition to those with data by Reader.Networks with the
+	// IncludeNetworksWithoutData option.
+	EmptyNetworks int
+	// Records is the number of distinct records that the networks point to.
+	Records int
+}
+
+// verifyContextCheckInterval is how many networks or values VerifyContext
+// checks between checks of its context.
+const verifyContextCheckInterval = 1024
+
 // Verify checks that the database is valid. It validates the search tree,
 // the data section, and the metadata section. This verifier is stricter than
 // the specification and may return errors on databases that are readable.
 func (r *Reader) Verify() error {
-	v := verifier{r}
+	return r.VerifyContext(context.Background())
+}
+
+// VerifyContext is the same as Verify, except that it stops and returns an
+// error wrapping ctx.Err() if ctx is canceled or its deadline passes before
+// the verification is complete. This bounds the time spent verifying
+// untrusted databases. The error may be distinguished from a problem with the
+// database using errors.Is with context.Canceled or
+// context.DeadlineExceeded.
+func (r *Reader) VerifyContext(ctx context.Context) error {
+	return r.verifyContext(ctx, verifyContextCheckInterval)
+}
+
+// verifyContext is VerifyContext with the interval at which ctx is checked,
+// which tests lower to check that verification stops.
+func (r *Reader) verifyContext(ctx context.Context, checkInterval int) error {
+	_, err := r.verifyReport(ctx, checkInterval)
+	return err
+}
+
+// VerifyReport checks that the database is valid, as with Verify, and
+// returns a summary of the networks and records that were checked along with
+// any warnings. This is intended for logging after verifying a database. If
+// the database is invalid, the error is returned instead.
+func (r *Reader) VerifyReport() (VerifyReport, error) {
+	return r.verifyReport(context.Background(), verifyContextCheckInterval)
+}
+
+// verifyReport verifies the database for VerifyContext and VerifyReport.
+func (r *Reader) verifyReport(ctx context.Context, checkInterval int) (VerifyReport, error) {
+	v := newVerifier(r, ctx)
+	v.checkInterval = checkInterval
 	if err := v.verifyMetadata(); err != nil {
-		return err
+		return VerifyReport{}, &VerifyError{Err: err, Section: VerifyMetadata, Offset: -1}
 	}
 
 	err := v.verifyDatabase()
 	runtime.KeepAlive(v.reader)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+
+	metadata := r.Metadata
+	if metadata.BuildEpoch == 0 {
+		v.report.Warnings = append(v.report.Warnings, "the metadata build_epoch is 0")
+	}
+	if len(metadata.Languages) == 0 {
+		v.report.Warnings = append(v.report.Warnings, "the metadata has no languages")
+	}
+	if v.report.Networks == 0 {
+		v.report.Warnings = append(v.report.Warnings, "the database has no networks with data")
+	}
+	return v.report, nil
+}
+
+// VerifyPointers checks that every pointer in the data section resolves to an
+// offset within the data section. Unlike Verify, it does not walk the search
+// tree or decode the records, making it a fast check for databases with
+// corrupt pointers.
+func (r *Reader) VerifyPointers() error {
+	v := newVerifier(r, context.Background())
+	err := v.verifyPointers()
+	runtime.KeepAlive(v.reader)
 	return err
 }
 
+// SelfCheck looks up sample pseudo-random addresses and checks that the
+// network and record returned by Lookup for each address match the network
+// containing it that Networks returns. Half of the addresses are chosen from
+// within the networks in the database and half uniformly from the address
+// space. The addresses are generated from seed, so a failing check may be
+// reproduced. A mismatch indicates a bug in the search tree traversal or a
+// corrupt search tree.
+//
+// This reads every network in the database and should not be called on a hot
+// path.
+func (r *Reader) SelfCheck(sample int, seed int64) error {
+	v := newVerifier(r, context.Background())
+	err := v.selfCheck(sample, seed)
+	runtime.KeepAlive(v.reader)
+	return err
+}
+
+type checkedNetwork struct {
+	prefix netip.Prefix
+	offset uint
+}
+
+func (v *verifier) selfCheck(sample int, seed int64) error {
+	r := v.reader
+	if r.buffer == nil {
+		return errors.New("cannot call SelfCheck on a closed database")
+	}
+
+	var networks []checkedNetwork
+	for result := range r.Networks(IncludeAliasedNetworks, IncludeNetworksWithoutData) {
+		if err := result.Err(); err != nil {
+			return err
+		}
+		networks = append(networks, checkedNetwork{treePrefix(result), result.offset})
+	}
+	if len(networks) == 0 {
+		return errors.New("networks returned no networks")
+	}
+	slices.SortFunc(networks, func(a, b checkedNetwork) int {
+		return a.prefix.Addr().Compare(b.prefix.Addr())
+	})
+
+	//nolint:gosec // the addresses do not need to be unpredictable
+	rng := rand.New(rand.NewPCG(uint64(seed), 0))
+	for i := range sample {
+		var ip16 [16]byte
+		bits := 0
+		if i%2 == 0 {
+			network := networks[rng.IntN(len(networks))].prefix
+			ip16 = network.Addr().As16()
+			bits = network.Bits()
+		}
+		for j := bits; j < 128; j++ {
+			ip16[j/8] |= byte(rng.UintN(2)) << (7 - j%8)
+		}
+		ip := netip.AddrFrom16(ip16)
+		if r.Metadata.IPVersion == 4 || isInIPv4Subtree(ip) {
+			ip = netip.AddrFrom4([4]byte(ip16[12:]))
+		}
+
+		result := r.Lookup(ip)
+		if err := result.Err(); err != nil {
+			return err
+		}
+		lookupPrefix := treePrefix(result)
+
+		ip16 = ip.As16()
+		if ip.Is4() {
+			ip16 = v4ToV16(ip).As16()
+		}
+		j, found := slices.BinarySearchFunc(
+			networks,
+			netip.AddrFrom16(ip16),
+			func(n checkedNetwork, ip netip.Addr) int {
+				return n.prefix.Addr().Compare(ip)
+			},
+		)
+		if !found {
+			j--
+		}
+		if j < 0 || !networks[j].prefix.Contains(netip.AddrFrom16(ip16)) {
+			return fmt.Errorf("networks did not return a network containing %s", ip)
+		}
+		if network := networks[j]; network.prefix != lookupPrefix || network.offset != result.offset {
+			return fmt.Errorf(
+				"lookup of %s returned %s (offset %d) but networks returned %s (offset %d)",
+				ip,
+				lookupPrefix,
+				result.offset,
+				network.prefix,
+				network.offset,
+			)
+		}
+	}
+	return nil
+}
+
+// treePrefix returns the network of a Result as an IPv6 prefix in the search
+// tree, i.e., with IPv4 addresses in ::/96.
+func treePrefix(result Result) netip.Prefix {
+	ip := result.ip
+	if ip.Is4() {
+		ip = v4ToV16(ip)
+	}
+	prefix, _ := ip.Prefix(int(result.prefixLen))
+	return prefix
+}
+
+func (v *verifier) verifyPointers() error {
+	d := v.reader.decoder
+	bufferLen := uint(len(d.buffer))
+
+	// The data section is a sequence of values. As the contents of maps and
+	// arrays immediately follow their control bytes, a linear scan visits
+	// every value, including nested ones.
+	var offset uint
+	for offset < bufferLen {
+		typeNum, size, newOffset, err := d.decodeCtrlData(offset)
+		if err != nil {
+			return v.dataSectionError(offset, newInvalidDatabaseError(
+				"received decoding error (%v) at offset of %v",
+				err,
+				offset,
+			))
+		}
+		switch typeNum {
+		case _Pointer:
+			var pointer uint
+			pointer, newOffset, err = d.decodePointer(size, newOffset)
+			if err != nil {
+				return v.dataSectionError(offset, newInvalidDatabaseError(
+					"received decoding error (%v) at offset of %v",
+					err,
+					offset,
+				))
+			}
+			if pointer >= bufferLen {
+				return v.dataSectionError(offset, newInvalidDatabaseError(
+					"pointer at offset %v points to %v, past the end of the data section (%v)",
+					offset,
+					pointer,
+					bufferLen,
+				))
+			}
+		case _Map, _Slice, _Bool:
+		default:
+			newOffset += size
+		}
+		offset = newOffset
+	}
+
+	if offset != bufferLen {
+		return v.dataSectionError(offset, newInvalidDatabaseError(
+			"unexpected data at the end of the data section (last offset: %v, end: %v)",
+			offset,
+			bufferLen,
+		))
+	}
+	return nil
+}
+
 func (v *verifier) verifyMetadata() error {
 	metadata := v.reader.Metadata
 
@@ -87,6 +339,10 @@ func (v *verifier) verifyMetadata() error {
 }
 
 func (v *verifier) verifyDatabase() error {
+	if err := v.checkContext(); err != nil {
+		return err
+	}
+
 	offsets, err := v.verifySearchTree()
 	if err != nil {
 		return err
@@ -102,23 +358,51 @@ func (v *verifier) verifyDatabase() error {
 func (v *verifier) verifySearchTree() (map[uint]bool, error) {
 	offsets := make(map[uint]bool)
 
-	for result := range v.reader.Networks() {
+	// Networks without data are included so that the context is also
+	// checked while traversing parts of the tree without data.
+	count := 0
+	for result := range v.reader.Networks(IncludeNetworksWithoutData) {
 		if err := result.Err(); err != nil {
-			return nil, err
+			return nil, &VerifyError{Err: err, Section: VerifySearchTree, Offset: -1}
 		}
+		count++
+		if count%v.checkInterval == 0 {
+			if err := v.checkContext(); err != nil {
+				return nil, err
+			}
+		}
+		if result.offset == notFound {
+			v.report.EmptyNetworks++
+			continue
+		}
+		v.report.Networks++
 		offsets[result.offset] = true
 	}
+	v.report.Records = len(offsets)
 	return offsets, nil
 }
 
+// checkContext returns an error if the context of a VerifyContext call is
+// done.
+func (v *verifier) checkContext() error {
+	if err := v.ctx.Err(); err != nil {
+		return fmt.Errorf("verification stopped: %w", err)
+	}
+	return nil
+}
+
 func (v *verifier) verifyDataSectionSeparator() error {
 	separatorStart := v.reader.Metadata.NodeCount * v.reader.Metadata.RecordSize / 4
 
 	separator := v.reader.buffer[separatorStart : separatorStart+dataSectionSeparatorSize]
 
-	for _, b := range separator {
+	for i, b := range separator {
 		if b != 0 {
-			return newInvalidDatabaseError("unexpected byte in data separator: %v", separator)
+			return &VerifyError{
+				Err:     newInvalidDatabaseError("unexpected byte in data separator: %v", separator),
+				Section: VerifyDataSectionSeparator,
+				Offset:  int(separatorStart) + i,
+			}
 		}
 	}
 	return nil
@@ -127,37 +411,62 @@ func (v *verifier) verifyDataSectionSeparator() error {
 func (v *verifier) verifyDataSection(offsets map[uint]bool) error {
 	pointerCount := len(offsets)
 
-	decoder := v.reader.decoder
+	// The options the Reader was opened with change how values are decoded,
+	// e.g., WithMaxDecodeDepth, so they are not used to check the values.
+	decoder := decoder{
+		buffer: v.reader.decoder.buffer,
+		opts:   decoderOptions{reportValueErrors: true},
+	}
 
 	var offset uint
 	bufferLen := uint(len(decoder.buffer))
-	for offset < bufferLen {
+	for count := 1; offset < bufferLen; count++ {
+		if count%v.checkInterval == 0 {
+			if err := v.checkContext(); err != nil {
+				return err
+			}
+		}
 		var data any
 		rv := reflect.ValueOf(&data)
 		newOffset, err := decoder.decode(offset, rv, 0)
 		if err != nil {
-			return newInvalidDatabaseError(
-				"received decoding error (%v) at offset of %v",
-				err,
-				offset,
-			)
+			var valueErr *valueError
+			if !errors.As(err, &valueErr) {
+				return v.dataSectionError(offset, newInvalidDatabaseError(
+					"received decoding error (%v) at offset of %v",
+					err,
+					offset,
+				))
+			}
+			// Report the nested value that is invalid rather than the
+			// record containing it.
+			return &VerifyError{
+				Err: newInvalidDatabaseError(
+					"received decoding error (%v) at offset of %v",
+					err,
+					valueErr.offset,
+				),
+				Type:    valueErr.typeNum.String(),
+				Section: VerifyDataSection,
+				Offset:  int(valueErr.offset),
+			}
 		}
 		if newOffset <= offset {
-			return newInvalidDatabaseError(
+			return v.dataSectionError(offset, newInvalidDatabaseError(
 				"data section offset unexpectedly went from %v to %v",
 				offset,
 				newOffset,
-			)
+			))
 		}
 
 		pointer := offset
 
 		if _, ok := offsets[pointer]; !ok {
-			return newInvalidDatabaseError(
+			return v.dataSectionError(offset, newInvalidDatabaseError(
 				"found data (%v) at %v that the search tree does not point to",
 				data,
 				pointer,
-			)
+			))
 		}
 		delete(offsets, pointer)
 
@@ -165,23 +474,37 @@ func (v *verifier) verifyDataSection(offsets map[uint]bool) error {
 	}
 
 	if offset != bufferLen {
-		return newInvalidDatabaseError(
+		return v.dataSectionError(offset, newInvalidDatabaseError(
 			"unexpected data at the end of the data section (last offset: %v, end: %v)",
 			offset,
 			bufferLen,
-		)
+		))
 	}
 
 	if len(offsets) != 0 {
-		return newInvalidDatabaseError(
-			"found %v pointers (of %v) in the search tree that we did not see in the data section",
-			len(offsets),
-			pointerCount,
-		)
+		return &VerifyError{
+			Err: newInvalidDatabaseError(
+				"found %v pointers (of %v) in the search tree that we did not see in the data section",
+				len(offsets),
+				pointerCount,
+			),
+			Section: VerifyDataSection,
+			Offset:  -1,
+		}
 	}
 	return nil
 }
 
+// dataSectionError returns a VerifyError for a problem with the value at
+// offset in the data section.
+func (v *verifier) dataSectionError(offset uint, err error) error {
+	verifyErr := &VerifyError{Err: err, Section: VerifyDataSection, Offset: int(offset)}
+	if typeNum, _, _, ctrlErr := v.reader.decoder.decodeCtrlData(offset); ctrlErr == nil {
+		verifyErr.Type = typeNum.String()
+	}
+	return verifyErr
+}
+
 func testError(
 	field string,
 	expected any,
@@ -1,6 +1,10 @@
 package maxminddb
 
 import (
+	"context"
+	"math"
+	"os"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -47,18 +51,231 @@ func TestVerifyOnGoodDatabases(t *testing.T) {
 	}
 }
 
+func TestVerifyWithReaderOptions(t *testing.T) {
+	reader, err := Open(
+		testFile("GeoIP2-City-Test.mmdb"),
+		WithMaxDecodeDepth(1),
+		WithoutPointerFollowing,
+		WithTrimStrings,
+		WithFloatAsString,
+		WithCollectErrors,
+		WithPanicRecovery,
+	)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.NoError(t, reader.Verify())
+	_, err = reader.VerifyReport()
+	require.NoError(t, err)
+}
+
 func TestVerifyOnBrokenDatabases(t *testing.T) {
-	databases := []string{
-		"GeoIP2-City-Test-Broken-Double-Format.mmdb",
-		"MaxMind-DB-test-broken-pointers-24.mmdb",
-		"MaxMind-DB-test-broken-search-tree-24.mmdb",
+	databases := map[string]VerifyError{
+		"GeoIP2-City-Test-Broken-Double-Format.mmdb": {
+			Section: VerifyDataSection,
+			Offset:  20,
+			Type:    "double",
+		},
+		"MaxMind-DB-test-broken-pointers-24.mmdb": {
+			Section: VerifyDataSection,
+			Offset:  4,
+			Type:    "pointer",
+		},
+		"MaxMind-DB-test-broken-search-tree-24.mmdb": {
+			Section: VerifySearchTree,
+			Offset:  -1,
+		},
 	}
 
-	for _, database := range databases {
+	for database, expected := range databases {
 		reader, err := Open(testFile(database))
 		require.NoError(t, err)
-		assert.Error(t, reader.Verify(),
+		err = reader.Verify()
+		assert.Error(t, err,
 			"Did not receive expected error when verifying %v", database,
 		)
+
+		var verifyErr *VerifyError
+		require.ErrorAs(t, err, &verifyErr, database)
+		assert.Equal(t, expected.Section, verifyErr.Section, database)
+		assert.Equal(t, expected.Offset, verifyErr.Offset, database)
+		assert.Equal(t, expected.Type, verifyErr.Type, database)
+		require.ErrorAs(t, err, &InvalidDatabaseError{}, database)
+	}
+}
+
+func TestVerifyOnBrokenDataSectionSeparator(t *testing.T) {
+	original, err := os.ReadFile(testFile("MaxMind-DB-test-ipv4-24.mmdb"))
+	require.NoError(t, err)
+	reader, err := FromBytes(original)
+	require.NoError(t, err)
+
+	separatorStart := int(reader.Metadata.NodeCount * reader.Metadata.RecordSize / 4)
+	broken := slices.Clone(original)
+	broken[separatorStart+3] = 1
+	reader, err = FromBytes(broken)
+	require.NoError(t, err)
+
+	var verifyErr *VerifyError
+	require.ErrorAs(t, reader.Verify(), &verifyErr)
+	assert.Equal(t, VerifyDataSectionSeparator, verifyErr.Section)
+	assert.Equal(t, separatorStart+3, verifyErr.Offset)
+	assert.Equal(t, "data section separator", verifyErr.Section.String())
+	assert.ErrorContains(t, verifyErr, "unexpected byte in data separator")
+}
+
+// countdownContext is a context that is canceled once Err has been called
+// remaining times.
+type countdownContext struct {
+	context.Context
+	remaining int
+	calls     int
+}
+
+func (c *countdownContext) Err() error {
+	c.calls++
+	if c.calls >= c.remaining {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestVerifyContext(t *testing.T) {
+	reader, err := Open(testFile("GeoIP2-City-Test.mmdb"))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.NoError(t, reader.VerifyContext(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = reader.VerifyContext(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.NotErrorAs(t, err, new(*VerifyError))
+
+	// The test databases are small, so the context is checked for every
+	// network and value to check that the search tree and data section
+	// verification can be stopped.
+	counter := &countdownContext{Context: context.Background(), remaining: math.MaxInt}
+	require.NoError(t, reader.verifyContext(counter, 1))
+	total := counter.calls
+	require.Greater(t, total, 2)
+
+	for _, remaining := range []int{1, 2, total / 2, total} {
+		err := reader.verifyContext(&countdownContext{Context: context.Background(), remaining: remaining}, 1)
+		require.ErrorIs(t, err, context.Canceled, "remaining %d", remaining)
+		require.NotErrorAs(t, err, new(*VerifyError))
+	}
+}
+
+func TestVerifyReport(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"GeoIP2-Connection-Type-Test.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			report, err := reader.VerifyReport()
+			require.NoError(t, err)
+
+			networks := 0
+			records := map[uintptr]bool{}
+			for result := range reader.Networks() {
+				require.NoError(t, result.Err())
+				networks++
+				records[result.Offset()] = true
+			}
+			allNetworks := 0
+			for range reader.Networks(IncludeNetworksWithoutData) {
+				allNetworks++
+			}
+			assert.Equal(t, networks, report.Networks)
+			assert.Equal(t, allNetworks-networks, report.EmptyNetworks)
+			assert.Equal(t, len(records), report.Records)
+			assert.Empty(t, report.Warnings)
+
+			reader.Metadata.BuildEpoch = 0
+			reader.Metadata.Languages = nil
+			report, err = reader.VerifyReport()
+			require.NoError(t, err)
+			assert.Equal(
+				t,
+				[]string{"the metadata build_epoch is 0", "the metadata has no languages"},
+				report.Warnings,
+			)
+		})
+	}
+
+	broken, err := Open(testFile("MaxMind-DB-test-broken-pointers-24.mmdb"))
+	require.NoError(t, err)
+	defer broken.Close()
+	_, err = broken.VerifyReport()
+	require.ErrorAs(t, err, new(*VerifyError))
+}
+
+func TestVerifyPointers(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-City-Test.mmdb",
+		"MaxMind-DB-test-decoder.mmdb",
+		"MaxMind-DB-test-nested.mmdb",
+	} {
+		reader, err := Open(testFile(database))
+		require.NoError(t, err)
+		require.NoError(t, reader.VerifyPointers(), database)
+		require.NoError(t, reader.Close())
+	}
+
+	broken, err := Open(testFile("MaxMind-DB-test-broken-pointers-24.mmdb"))
+	require.NoError(t, err)
+	defer broken.Close()
+	err = broken.VerifyPointers()
+	require.Error(t, err)
+	assert.Regexp(t, `pointer at offset \d+ points to \d+, past the end`, err.Error())
+
+	var verifyErr *VerifyError
+	require.ErrorAs(t, err, &verifyErr)
+	assert.Equal(t, VerifyDataSection, verifyErr.Section)
+	assert.Equal(t, 4, verifyErr.Offset)
+	assert.Equal(t, "pointer", verifyErr.Type)
+}
+
+func TestSelfCheck(t *testing.T) {
+	for _, database := range []string{
+		"GeoIP2-Anonymous-IP-Test.mmdb",
+		"GeoIP2-City-Test.mmdb",
+		"GeoIP2-Connection-Type-Test.mmdb",
+		"GeoIP2-Country-Test.mmdb",
+		"GeoIP2-Domain-Test.mmdb",
+		"GeoIP2-ISP-Test.mmdb",
+		"GeoIP2-Precision-Enterprise-Test.mmdb",
+		"MaxMind-DB-no-ipv4-search-tree.mmdb",
+		"MaxMind-DB-test-decoder.mmdb",
+		"MaxMind-DB-test-ipv4-24.mmdb",
+		"MaxMind-DB-test-ipv4-28.mmdb",
+		"MaxMind-DB-test-ipv4-32.mmdb",
+		"MaxMind-DB-test-ipv6-24.mmdb",
+		"MaxMind-DB-test-ipv6-32.mmdb",
+		"MaxMind-DB-test-mixed-24.mmdb",
+		"MaxMind-DB-test-mixed-32.mmdb",
+	} {
+		t.Run(database, func(t *testing.T) {
+			reader, err := Open(testFile(database))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			for seed := range int64(4) {
+				require.NoError(t, reader.SelfCheck(1000, seed))
+			}
+		})
 	}
+
+	broken, err := Open(testFile("MaxMind-DB-test-broken-search-tree-24.mmdb"))
+	require.NoError(t, err)
+	defer broken.Close()
+	require.Error(t, broken.SelfCheck(1000, 0))
 }